@@ -0,0 +1,72 @@
+// Package chunk splits source files into parts that are embedded separately.
+package chunk
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// Options configures how files are split.
+type Options struct {
+	// Lines configures the line-window chunker used for non-Go files.
+	Lines LineOptions
+}
+
+// DefaultOptions returns the chunking options used for new projects.
+func DefaultOptions() Options {
+	return Options{
+		Lines: LineOptions{MaxLines: DefaultMaxLines, Overlap: DefaultOverlap},
+	}
+}
+
+// Split returns the chunks of a file. Go sources are split per top-level
+// declaration, everything else (and Go that fails to parse) is split into
+// overlapping line windows.
+func Split(path string, content []byte, opts Options) []models.Chunk {
+	if strings.ToLower(filepath.Ext(path)) == ".go" {
+		chunks, err := Go(content)
+		if err == nil && len(chunks) > 0 {
+			return chunks
+		}
+	}
+
+	return Lines(content, opts.Lines)
+}
+
+// Whole returns a single chunk covering all of content.
+func Whole(content []byte) models.Chunk {
+	return models.Chunk{
+		StartLine: 1,
+		EndLine:   countLines(content),
+		Content:   string(content),
+	}
+}
+
+// countLines returns the number of lines in content, counting a trailing
+// line without newline.
+func countLines(content []byte) int {
+	if len(content) == 0 {
+		return 0
+	}
+	n := strings.Count(string(content), "\n")
+	if content[len(content)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// lineRange returns lines start to end (1-based, inclusive) of lines.
+func lineRange(lines []string, start, end int) string {
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return ""
+	}
+	return strings.Join(lines[start-1:end], "\n")
+}
@@ -0,0 +1,53 @@
+package chunk
+
+import (
+	"unicode/utf8"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// Fit makes sure no chunk exceeds maxTokens approximate tokens. Oversized
+// chunks are split into line windows that keep the symbol of the original;
+// single lines that still exceed the budget are truncated.
+// It reports whether any chunk was split or truncated.
+func Fit(chunks []models.Chunk, maxTokens int) (fitted []models.Chunk, split, truncated bool) {
+	if maxTokens <= 0 {
+		return chunks, false, false
+	}
+
+	for _, c := range chunks {
+		if EstimateTokens(c.Content) <= maxTokens {
+			fitted = append(fitted, c)
+			continue
+		}
+
+		split = true
+		offset := c.StartLine - 1
+		if offset < 0 {
+			offset = 0
+		}
+		for _, part := range Lines([]byte(c.Content), LineOptions{MaxLines: 1 << 30, MaxTokens: maxTokens}) {
+			part.Symbol = c.Symbol
+			part.StartLine += offset
+			part.EndLine += offset
+			if EstimateTokens(part.Content) > maxTokens {
+				part.Content = truncate(part.Content, maxTokens*4)
+				truncated = true
+			}
+			fitted = append(fitted, part)
+		}
+	}
+
+	return fitted, split, truncated
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,47 @@
+package chunk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFit(t *testing.T) {
+	t.Run("small chunks are kept", func(t *testing.T) {
+		chunks := []models.Chunk{{Symbol: "a", StartLine: 1, EndLine: 1, Content: "short"}}
+		fitted, split, truncated := Fit(chunks, 100)
+		assert.Equal(t, chunks, fitted)
+		assert.False(t, split)
+		assert.False(t, truncated)
+	})
+
+	t.Run("oversized chunk is split keeping symbol and line numbers", func(t *testing.T) {
+		line := strings.Repeat("x", 39) // 10 tokens with the newline
+		content := strings.Join([]string{line, line, line, line}, "\n")
+		chunks := []models.Chunk{{Symbol: "Big", StartLine: 10, EndLine: 13, Content: content}}
+
+		fitted, split, truncated := Fit(chunks, 22)
+		assert.True(t, split)
+		assert.False(t, truncated)
+		require.Len(t, fitted, 2)
+		assert.Equal(t, "Big", fitted[0].Symbol)
+		assert.Equal(t, 10, fitted[0].StartLine)
+		assert.Equal(t, 11, fitted[0].EndLine)
+		assert.Equal(t, 12, fitted[1].StartLine)
+		assert.Equal(t, 13, fitted[1].EndLine)
+	})
+
+	t.Run("single long line is truncated", func(t *testing.T) {
+		chunks := []models.Chunk{{StartLine: 1, EndLine: 1, Content: strings.Repeat("é", 100)}}
+
+		fitted, split, truncated := Fit(chunks, 10)
+		assert.True(t, split)
+		assert.True(t, truncated)
+		require.Len(t, fitted, 1)
+		assert.LessOrEqual(t, len(fitted[0].Content), 40)
+		assert.True(t, strings.HasPrefix(strings.Repeat("é", 100), fitted[0].Content))
+	})
+}
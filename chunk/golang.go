@@ -0,0 +1,108 @@
+package chunk
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// Go splits Go source into a header chunk (package clause and imports) and
+// one chunk per top-level func, method, type, const or var declaration.
+// Doc comments are kept with their declaration.
+func Go(content []byte) ([]models.Chunk, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse go source: %w", err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	line := func(pos token.Pos) int {
+		return fset.Position(pos).Line
+	}
+
+	headerEnd := line(f.Name.End())
+	var chunks []models.Chunk
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			headerEnd = line(gen.End())
+			continue
+		}
+
+		start := line(decl.Pos())
+		if doc := declDoc(decl); doc != nil {
+			start = line(doc.Pos())
+		}
+		end := line(decl.End())
+
+		chunks = append(chunks, models.Chunk{
+			Symbol:    declSymbol(decl),
+			StartLine: start,
+			EndLine:   end,
+			Content:   lineRange(lines, start, end),
+		})
+	}
+
+	header := models.Chunk{
+		Symbol:    "package " + f.Name.Name,
+		StartLine: 1,
+		EndLine:   headerEnd,
+		Content:   lineRange(lines, 1, headerEnd),
+	}
+
+	return append([]models.Chunk{header}, chunks...), nil
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+// declSymbol names a declaration: "Func", "(*Recv).Method", "Type" or the
+// comma separated names of a type, const or var block.
+func declSymbol(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return fmt.Sprintf("(%s).%s", receiverType(d.Recv.List[0].Type), d.Name.Name)
+		}
+		return d.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range s.Names {
+					names = append(names, name.Name)
+				}
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package chunk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goSource = `// Package demo is a demo.
+package demo
+
+import (
+	"fmt"
+)
+
+// Greeter greets.
+type Greeter struct {
+	Name string
+}
+
+const (
+	A = 1
+	B = 2
+)
+
+var defaultName = "world"
+
+// Greet returns a greeting.
+func (g *Greeter) Greet() string {
+	return fmt.Sprintf("hello %s", g.Name)
+}
+
+func (l List[T]) Len() int { return len(l) }
+
+func main() {
+	fmt.Println(defaultName)
+}
+`
+
+func TestGo(t *testing.T) {
+	chunks, err := Go([]byte(goSource))
+	require.NoError(t, err)
+
+	type expected struct {
+		symbol     string
+		start, end int
+	}
+	want := []expected{
+		{"package demo", 1, 6},
+		{"Greeter", 8, 11},
+		{"A, B", 13, 16},
+		{"defaultName", 18, 18},
+		{"(*Greeter).Greet", 20, 23},
+		{"(List).Len", 25, 25},
+		{"main", 27, 29},
+	}
+
+	require.Len(t, chunks, len(want))
+	for i, w := range want {
+		assert.Equal(t, w.symbol, chunks[i].Symbol)
+		assert.Equal(t, w.start, chunks[i].StartLine, w.symbol)
+		assert.Equal(t, w.end, chunks[i].EndLine, w.symbol)
+	}
+
+	assert.Equal(t, "// Greet returns a greeting.\nfunc (g *Greeter) Greet() string {\n\treturn fmt.Sprintf(\"hello %s\", g.Name)\n}", chunks[4].Content)
+}
+
+func TestSplit(t *testing.T) {
+	t.Run("go file is split per declaration", func(t *testing.T) {
+		chunks := Split("/a/b.go", []byte(goSource), DefaultOptions())
+		assert.Len(t, chunks, 7)
+	})
+
+	t.Run("invalid go falls back to whole file", func(t *testing.T) {
+		chunks := Split("/a/b.go", []byte("package x\nfunc {"), DefaultOptions())
+		require.Len(t, chunks, 1)
+		assert.Equal(t, "", chunks[0].Symbol)
+		assert.Equal(t, 1, chunks[0].StartLine)
+		assert.Equal(t, 2, chunks[0].EndLine)
+	})
+
+	t.Run("small files are a single chunk", func(t *testing.T) {
+		chunks := Split("/a/b.py", []byte("a = 1\nb = 2\n"), DefaultOptions())
+		require.Len(t, chunks, 1)
+		assert.Equal(t, 2, chunks[0].EndLine)
+		assert.Equal(t, "a = 1\nb = 2\n", chunks[0].Content)
+	})
+}
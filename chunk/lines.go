@@ -0,0 +1,163 @@
+package chunk
+
+import (
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+const (
+	// DefaultMaxLines is the default line window for non-Go files.
+	DefaultMaxLines = 60
+	// DefaultOverlap is the default number of lines shared by adjacent windows.
+	DefaultOverlap = 10
+)
+
+// LineOptions configures the line-window chunker.
+type LineOptions struct {
+	// MaxLines is the maximum number of lines per chunk. Zero disables
+	// line windows and keeps the whole file as one chunk.
+	MaxLines int
+	// MaxTokens optionally limits chunks by approximate token count.
+	MaxTokens int
+	// Overlap is the number of lines repeated at the start of the next chunk.
+	Overlap int
+}
+
+// EstimateTokens approximates the number of tokens in s, assuming about four
+// bytes per token as is typical for code with BPE tokenizers.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// Lines splits content into windows of at most opts.MaxLines lines and
+// opts.MaxTokens approximate tokens, overlapping by opts.Overlap lines.
+// Within the second half of a window it prefers to cut at a blank line or
+// where indentation drops, so chunks tend to end on block boundaries.
+func Lines(content []byte, opts LineOptions) []models.Chunk {
+	lines := strings.Split(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if opts.MaxLines <= 0 || len(lines) == 0 {
+		return []models.Chunk{Whole(content)}
+	}
+
+	overlap := opts.Overlap
+	if overlap >= opts.MaxLines {
+		overlap = opts.MaxLines - 1
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+
+	var chunks []models.Chunk
+	for start := 0; start < len(lines); {
+		end := windowEnd(lines, start, opts)
+		if end < len(lines) {
+			end = preferredCut(lines, start, end)
+		} else if start == 0 {
+			return []models.Chunk{Whole(content)}
+		}
+
+		chunks = append(chunks, models.Chunk{
+			StartLine: start + 1,
+			EndLine:   end,
+			Content:   strings.Join(lines[start:end], "\n"),
+		})
+
+		if end >= len(lines) {
+			break
+		}
+		next := end - overlap
+		if next <= start {
+			next = start + 1
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// windowEnd returns the exclusive end index of the largest window starting at
+// start that fits the limits. A window always holds at least one line.
+func windowEnd(lines []string, start int, opts LineOptions) int {
+	end := start + opts.MaxLines
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	if opts.MaxTokens > 0 {
+		tokens := 0
+		for i := start; i < end; i++ {
+			tokens += EstimateTokens(lines[i]) + 1
+			if tokens > opts.MaxTokens && i > start {
+				return i
+			}
+		}
+	}
+
+	return end
+}
+
+// preferredCut moves end back to a natural boundary in the second half of
+// the window [start, end): first choice is a blank line followed by a less
+// indented line, then any blank line, then any indentation drop.
+func preferredCut(lines []string, start, end int) int {
+	minEnd := start + (end-start+1)/2
+	best, bestScore := end, 0
+	for cut := end; cut > minEnd; cut-- {
+		score := cutScore(lines, cut)
+		if score > bestScore {
+			best, bestScore = cut, score
+		}
+	}
+	return best
+}
+
+// cutScore rates a cut between lines[cut-1] and lines[cut].
+func cutScore(lines []string, cut int) int {
+	prevBlank := strings.TrimSpace(lines[cut-1]) == ""
+	drop := indentation(lines[cut]) < indentation(previousNonBlank(lines, cut))
+	topLevel := indentation(lines[cut]) == 0 && strings.TrimSpace(lines[cut]) != ""
+
+	switch {
+	case prevBlank && (drop || topLevel):
+		return 3
+	case prevBlank:
+		return 2
+	case drop:
+		return 1
+	}
+	return 0
+}
+
+func previousNonBlank(lines []string, cut int) string {
+	for i := cut - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			return lines[i]
+		}
+	}
+	return ""
+}
+
+// indentation returns the width of the leading whitespace of a non-blank
+// line, counting tabs as four columns. Blank lines have no indentation.
+func indentation(line string) int {
+	if strings.TrimSpace(line) == "" {
+		return 0
+	}
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
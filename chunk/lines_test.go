@@ -0,0 +1,57 @@
+package chunk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	t.Run("disabled keeps whole file", func(t *testing.T) {
+		chunks := Lines([]byte(numberedLines(100)), LineOptions{})
+		require.Len(t, chunks, 1)
+		assert.Equal(t, 1, chunks[0].StartLine)
+		assert.Equal(t, 100, chunks[0].EndLine)
+	})
+
+	t.Run("windows overlap", func(t *testing.T) {
+		chunks := Lines([]byte(numberedLines(25)), LineOptions{MaxLines: 10, Overlap: 2})
+
+		var ranges [][2]int
+		for _, c := range chunks {
+			ranges = append(ranges, [2]int{c.StartLine, c.EndLine})
+		}
+		assert.Equal(t, [][2]int{{1, 10}, {9, 18}, {17, 25}}, ranges)
+		assert.True(t, strings.HasPrefix(chunks[1].Content, "line 9\nline 10\n"))
+	})
+
+	t.Run("prefers blank line before top level code", func(t *testing.T) {
+		source := "def a():\n    x = 1\n    y = 2\n    z = 3\n\ndef b():\n    return 1\n    pass\n\n    pass\nend\n"
+		chunks := Lines([]byte(source), LineOptions{MaxLines: 7})
+
+		require.GreaterOrEqual(t, len(chunks), 2)
+		assert.Equal(t, 5, chunks[0].EndLine)
+		assert.Equal(t, 6, chunks[1].StartLine)
+		assert.True(t, strings.HasPrefix(chunks[1].Content, "def b():"))
+	})
+
+	t.Run("token budget", func(t *testing.T) {
+		long := strings.Repeat("x", 400) + "\n"
+		chunks := Lines([]byte(strings.Repeat(long, 10)), LineOptions{MaxLines: 100, MaxTokens: 250})
+		require.Len(t, chunks, 5)
+		for _, c := range chunks {
+			assert.Equal(t, 2, c.EndLine-c.StartLine+1)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// DefaultTimeout is used when neither options nor environment set a timeout.
+const DefaultTimeout = time.Minute
+
+// Embedder turns text into embedding vectors using a specific provider and model.
+type Embedder interface {
+	// Embed returns one vector per input, in input order.
+	Embed(ctx context.Context, inputs []string) ([]models.Embedding, error)
+	// Dimensions returns the length of the vectors produced by the model.
+	Dimensions(ctx context.Context) (int, error)
+	// Name returns the provider name the embedder was registered under.
+	Name() string
+}
+
+// Options configures how an Embedder reaches its provider.
+// Zero values are filled from the environment and then from provider defaults.
+type Options struct {
+	BaseURL string
+	APIKey  string
+	Timeout time.Duration
+}
+
+// Factory creates an Embedder for model using fully resolved options.
+type Factory func(model string, opts Options) Embedder
+
+// Provider describes a registered embedding provider.
+type Provider struct {
+	Name    string
+	Factory Factory
+	// Defaults are used for options not set explicitly or via environment.
+	Defaults Options
+	// APIKeyEnv lists extra environment variables checked for an API key,
+	// after CODESEARCH_<NAME>_API_KEY.
+	APIKeyEnv []string
+	// BaseURLEnv lists extra environment variables checked for a base URL,
+	// after CODESEARCH_<NAME>_BASE_URL.
+	BaseURLEnv []string
+	// Offline providers embed in process and need no base URL.
+	Offline bool
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+// Register makes a provider available to New under p.Name.
+func Register(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name] = p
+}
+
+// Names returns the registered provider names in alphabetical order.
+func Names() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates an Embedder for the named provider and model.
+func New(name, model string, opts Options) (Embedder, error) {
+	providersMu.RLock()
+	p, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported client: %s (available: %s)", name, strings.Join(Names(), ", "))
+	}
+
+	resolved, err := p.resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Factory(model, resolved), nil
+}
+
+// resolve fills unset options from the environment and provider defaults.
+func (p Provider) resolve(opts Options) (Options, error) {
+	prefix := "CODESEARCH_" + strings.ToUpper(p.Name) + "_"
+
+	if opts.BaseURL == "" {
+		opts.BaseURL = firstEnv(append([]string{prefix + "BASE_URL"}, p.BaseURLEnv...)...)
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = p.Defaults.BaseURL
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	if opts.BaseURL == "" && !p.Offline {
+		return Options{}, fmt.Errorf("no base URL configured for client %s, set %sBASE_URL", p.Name, prefix)
+	}
+
+	if opts.APIKey == "" {
+		opts.APIKey = firstEnv(append([]string{prefix + "API_KEY"}, p.APIKeyEnv...)...)
+	}
+	if opts.APIKey == "" {
+		opts.APIKey = p.Defaults.APIKey
+	}
+
+	if opts.Timeout == 0 {
+		if raw := os.Getenv("CODESEARCH_TIMEOUT"); raw != "" {
+			timeout, err := time.ParseDuration(raw)
+			if err != nil {
+				return Options{}, fmt.Errorf("invalid CODESEARCH_TIMEOUT %q: %w", raw, err)
+			}
+			opts.Timeout = timeout
+		}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = p.Defaults.Timeout
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	return opts, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// dimensionProbe lazily detects and caches the vector size of an embedder.
+type dimensionProbe struct {
+	once       sync.Once
+	dimensions int
+	err        error
+}
+
+func (d *dimensionProbe) get(ctx context.Context, e Embedder) (int, error) {
+	d.once.Do(func() {
+		vectors, err := e.Embed(ctx, []string{"1"})
+		if err != nil {
+			d.err = fmt.Errorf("error generating embedding for dimensions: %w", err)
+			return
+		}
+		d.dimensions = len(vectors[0])
+		if d.dimensions == 0 {
+			d.err = fmt.Errorf("received empty embedding dimensions")
+		}
+	})
+	return d.dimensions, d.err
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUnknownClient(t *testing.T) {
+	_, err := New("does-not-exist", "model", Options{})
+	assert.ErrorContains(t, err, "unsupported client")
+}
+
+func TestProviderResolve(t *testing.T) {
+	p := Provider{
+		Name:      "test",
+		Defaults:  Options{BaseURL: "http://default:1/", APIKey: "default-key"},
+		APIKeyEnv: []string{"TEST_FALLBACK_KEY"},
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		opts, err := p.resolve(Options{})
+		require.NoError(t, err)
+		assert.Equal(t, "http://default:1", opts.BaseURL)
+		assert.Equal(t, "default-key", opts.APIKey)
+		assert.Equal(t, DefaultTimeout, opts.Timeout)
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("CODESEARCH_TEST_BASE_URL", "http://env:2")
+		t.Setenv("TEST_FALLBACK_KEY", "env-key")
+		t.Setenv("CODESEARCH_TIMEOUT", "5s")
+
+		opts, err := p.resolve(Options{})
+		require.NoError(t, err)
+		assert.Equal(t, "http://env:2", opts.BaseURL)
+		assert.Equal(t, "env-key", opts.APIKey)
+		assert.Equal(t, 5*time.Second, opts.Timeout)
+	})
+
+	t.Run("explicit options win", func(t *testing.T) {
+		t.Setenv("CODESEARCH_TEST_BASE_URL", "http://env:2")
+
+		opts, err := p.resolve(Options{BaseURL: "http://flag:3", APIKey: "flag-key", Timeout: time.Second})
+		require.NoError(t, err)
+		assert.Equal(t, "http://flag:3", opts.BaseURL)
+		assert.Equal(t, "flag-key", opts.APIKey)
+		assert.Equal(t, time.Second, opts.Timeout)
+	})
+}
+
+func TestOpenAICompatibleEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req models.EmbeddingRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "embed-model", req.Model)
+
+		// Respond in reverse order to check that indexes are honoured.
+		var res models.EmbeddingResponse
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			res.Data = append(res.Data, models.EmbeddingData{
+				Index:     i,
+				Embedding: models.Embedding{float64(len(req.Input[i])), 1},
+			})
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	defer server.Close()
+
+	embedder, err := New("openai", "embed-model", Options{BaseURL: server.URL, APIKey: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "openai", embedder.Name())
+
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "bbb", "cc"})
+	require.NoError(t, err)
+	require.Len(t, vectors, 3)
+	assert.Equal(t, models.Embedding{1, 1}, vectors[0])
+	assert.Equal(t, models.Embedding{3, 1}, vectors[1])
+	assert.Equal(t, models.Embedding{2, 1}, vectors[2])
+
+	dims, err := embedder.Dimensions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, dims)
+}
+
+func TestEmbedRetries(t *testing.T) {
+	defer func(interval time.Duration) { retryInterval = interval }(retryInterval)
+	retryInterval = time.Millisecond
+
+	var requests int
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "slow down", status)
+			return
+		}
+		res := models.EmbeddingResponse{Data: []models.EmbeddingData{{Index: 0, Embedding: models.Embedding{1, 0}}}}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	defer server.Close()
+
+	embedder, err := New("openai", "embed-model", Options{BaseURL: server.URL})
+	require.NoError(t, err)
+
+	// Rate limits and server errors are retried.
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		requests, status = 0, code
+		_, err = embedder.Embed(context.Background(), []string{"a"})
+		require.NoError(t, err, code)
+		assert.Equal(t, 2, requests, code)
+	}
+
+	// A rejected request fails on the first attempt.
+	requests, status = 0, http.StatusBadRequest
+	_, err = embedder.Embed(context.Background(), []string{"a"})
+	assert.ErrorContains(t, err, "slow down")
+	assert.Equal(t, 1, requests)
+
+	// Cancelling stops waiting for the next attempt.
+	retryInterval = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelling := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer cancelling.Close()
+	embedder, err = New("openai", "embed-model", Options{BaseURL: cancelling.URL})
+	require.NoError(t, err)
+	_, err = embedder.Embed(ctx, []string{"a"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+func init() {
+	Register(Provider{
+		Name:    "hash",
+		Factory: newHash,
+		Offline: true,
+	})
+}
+
+// HashDimensions is the length of the vectors of the hash client.
+const HashDimensions = 512
+
+// hashTrigramWeight is the weight of character trigrams relative to words.
+// Trigrams let related spellings such as "config" and "configuration" meet
+// without dominating exact word matches.
+const hashTrigramWeight = 0.3
+
+// Hash embeds text without a model by hashing its words and character
+// trigrams into a fixed number of dimensions. Identifiers are also split into
+// their camelCase and snake_case parts, so "parse config" is close to
+// parseConfig. The vectors are deterministic and need no network, which makes
+// them suitable for tests and offline demos; they capture shared vocabulary,
+// not meaning.
+type Hash struct{}
+
+func newHash(model string, opts Options) Embedder {
+	return Hash{}
+}
+
+func (Hash) Embed(ctx context.Context, inputs []string) ([]models.Embedding, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	vectors := make([]models.Embedding, len(inputs))
+	for i, input := range inputs {
+		vectors[i] = hashVector(input)
+	}
+	return vectors, nil
+}
+
+func (Hash) Dimensions(context.Context) (int, error) {
+	return HashDimensions, nil
+}
+
+func (Hash) Name() string {
+	return "hash"
+}
+
+// hashBias is the weight of the dimension shared by all texts, relative to
+// the hashed features. Like the common direction of real embedding models, it
+// moves the cosine c of two texts to (1+c)/2: texts without shared features
+// end up at a similarity of about zero and texts sharing some vocabulary above
+// the default minimum similarity of find.
+const hashBias = 1.0
+
+// hashVector returns the normalized feature hashing vector of text. Repeated
+// features count logarithmically.
+func hashVector(text string) models.Embedding {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			counts["w:"+strings.ToLower(word)]++
+		}
+		for _, part := range parts {
+			part = strings.ToLower(part)
+			counts["w:"+part]++
+			padded := []rune("^" + part + "$")
+			for i := 0; i+3 <= len(padded); i++ {
+				counts["g:"+string(padded[i:i+3])]++
+			}
+		}
+	}
+
+	// The first dimension is shared by all texts, see hashBias; features
+	// are hashed into the others.
+	vector := make(models.Embedding, HashDimensions)
+	for feature, count := range counts {
+		weight := 1 + math.Log(float64(count))
+		if strings.HasPrefix(feature, "g:") {
+			weight *= hashTrigramWeight
+		}
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[1+sum%(HashDimensions-1)] += weight
+	}
+
+	normalize(vector)
+	vector[0] = hashBias
+	normalize(vector)
+	return vector
+}
+
+// normalize scales v to unit length, unless it is all zeros.
+func normalize(v models.Embedding) {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// splitIdentifier splits a word at camelCase boundaries and between letters
+// and digits: "parseHTTPConfig2" becomes "parse", "HTTP", "Config" and "2".
+func splitIdentifier(word string) []string {
+	runes := []rune(word)
+	var parts []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+			unicode.IsLetter(prev) != unicode.IsLetter(cur) ||
+			// The last capital of an acronym starts the next word: HTTPServer.
+			unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if boundary {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
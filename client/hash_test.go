@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashEmbed(t *testing.T) {
+	e, err := New("hash", "", Options{})
+	require.NoError(t, err, "no base URL is needed")
+	ctx := context.Background()
+
+	dims, err := e.Dimensions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, HashDimensions, dims)
+
+	vectors, err := e.Embed(ctx, []string{
+		"parse config",
+		"func parseConfig(path string) (*Config, error)",
+		"func renderTemplate(w io.Writer) error",
+		"",
+	})
+	require.NoError(t, err)
+	require.Len(t, vectors, 4)
+
+	again, err := e.Embed(ctx, []string{"parse config"})
+	require.NoError(t, err)
+	assert.Equal(t, vectors[0], again[0], "vectors are deterministic")
+
+	for _, v := range vectors {
+		assert.InDelta(t, 1, norm(v), 1e-9)
+	}
+	assert.Greater(t, dot(vectors[0], vectors[1]), dot(vectors[0], vectors[2]))
+
+	unrelated, err := e.Embed(ctx, []string{"qwerty"})
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, dot(vectors[0], unrelated[0]), 0.1, "texts without shared features")
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	assert.Equal(t, []string{"parse", "HTTP", "Config", "2"}, splitIdentifier("parseHTTPConfig2"))
+	assert.Equal(t, []string{"Server"}, splitIdentifier("Server"))
+}
+
+func norm(v models.Embedding) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+func dot(a, b models.Embedding) float64 {
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
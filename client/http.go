@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	fastshot "github.com/opus-domini/fast-shot"
+)
+
+// maxAttempts is how often an embedding request is sent before giving up.
+const maxAttempts = 4
+
+// retryInterval is the delay before the first retry of a failed request; it
+// doubles with each further attempt.
+var retryInterval = 30 * time.Second
+
+func newHTTPClient(opts Options) fastshot.ClientHttpMethods {
+	c := fastshot.NewClient(opts.BaseURL)
+	if opts.APIKey != "" {
+		c.Auth().BearerToken(opts.APIKey)
+	}
+
+	return c.Config().SetTimeout(opts.Timeout).
+		Config().SetFollowRedirects(true).
+		Header().Add("Content-Type", "application/json").
+		Build()
+}
+
+// postEmbeddings sends an embedding request to path and maps the response
+// back to one vector per input.
+func postEmbeddings(ctx context.Context, c fastshot.ClientHttpMethods, path, model string, inputs []string) ([]models.Embedding, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("inputs cannot be empty")
+	}
+	for i, input := range inputs {
+		if input == "" {
+			return nil, fmt.Errorf("input %d cannot be empty", i)
+		}
+	}
+
+	req := models.EmbeddingRequest{
+		Model: model,
+		Input: inputs,
+	}
+
+	// Rate limits, server errors and failed connections are retried. Other
+	// errors mean the request itself was rejected, e.g. a batch that is too
+	// large, and fail right away so that callers can split it up.
+	delay := retryInterval
+	for attempt := 1; ; attempt++ {
+		res, retry, err := sendEmbeddings(ctx, c, path, req)
+		if err == nil {
+			return res.Vectors(len(inputs))
+		}
+		if !retry || attempt == maxAttempts {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// sendEmbeddings sends req once. On error it reports whether sending it again
+// may succeed.
+func sendEmbeddings(ctx context.Context, c fastshot.ClientHttpMethods, path string, req models.EmbeddingRequest) (models.EmbeddingResponse, bool, error) {
+	resp, err := c.
+		POST(path).
+		Context().Set(ctx).
+		Header().Add("Accept", "application/json").
+		Body().AsJSON(req).
+		Send()
+
+	if err != nil {
+		return models.EmbeddingResponse{}, ctx.Err() == nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body().Close()
+
+	var res models.EmbeddingResponse
+	err = parseHTTPResponse(*resp, &res)
+	if err != nil {
+		code := resp.Status().Code()
+		return models.EmbeddingResponse{}, code == http.StatusTooManyRequests || code >= http.StatusInternalServerError, err
+	}
+	return res, false, nil
+}
+
+func parseHTTPResponse[T any](resp fastshot.Response, result *T) error {
+	if resp.Status().IsError() {
+		msg, err := resp.Body().AsString()
+		if err != nil {
+			return fmt.Errorf("failed to read error response: %w", err)
+		}
+		return errors.New(msg)
+	}
+
+	err := resp.Body().AsJSON(result)
+	if err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// ProbeText is embedded to recognise the model behind an embedder. Changing
+// it invalidates all stored fingerprints.
+const ProbeText = "func add(a, b int) int { return a + b }"
+
+// fingerprintLength is the number of leading probe vector components kept.
+const fingerprintLength = 16
+
+// fingerprintMinSimilarity is the cosine similarity above which two
+// fingerprints are taken to come from the same model. Providers are not
+// always deterministic, so exact equality is not required.
+const fingerprintMinSimilarity = 0.99
+
+// ErrModelMismatch is returned when a model no longer produces vectors
+// compatible with an existing index.
+var ErrModelMismatch = errors.New("embedding model changed")
+
+// Identity records which model produced the vectors of an index.
+type Identity struct {
+	Client      string
+	Model       string
+	Dimensions  int
+	Fingerprint string // leading components of the ProbeText vector
+}
+
+// NewIdentity derives an identity from the vector of ProbeText.
+func NewIdentity(clientName, model string, probe models.Embedding) Identity {
+	n := min(len(probe), fingerprintLength)
+	parts := make([]string, n)
+	for i, v := range probe[:n] {
+		parts[i] = strconv.FormatFloat(v, 'f', 5, 64)
+	}
+	return Identity{
+		Client:      clientName,
+		Model:       model,
+		Dimensions:  len(probe),
+		Fingerprint: strings.Join(parts, ","),
+	}
+}
+
+// Identify embeds ProbeText to identify the model behind e.
+func Identify(ctx context.Context, e Embedder, model string) (Identity, error) {
+	vectors, err := e.Embed(ctx, []string{ProbeText})
+	if err != nil {
+		return Identity{}, fmt.Errorf("error generating probe embedding: %w", err)
+	}
+	return NewIdentity(e.Name(), model, vectors[0]), nil
+}
+
+// Check returns an ErrModelMismatch error when current cannot be used with an
+// index built by stored. Unknown stored values, from indexes built before
+// identities were recorded, are not checked.
+func (stored Identity) Check(current Identity) error {
+	if stored.Dimensions != 0 && stored.Dimensions != current.Dimensions {
+		return fmt.Errorf("%w: the index was built with %d dimensions but %s model %s now returns %d",
+			ErrModelMismatch, stored.Dimensions, current.Client, current.Model, current.Dimensions)
+	}
+
+	if stored.Fingerprint == "" {
+		return nil
+	}
+	similarity, err := fingerprintSimilarity(stored.Fingerprint, current.Fingerprint)
+	if err != nil {
+		return err
+	}
+	if similarity < fingerprintMinSimilarity {
+		return fmt.Errorf("%w: %s model %s returns different vectors than when the index was built (similarity %.3f)",
+			ErrModelMismatch, current.Client, current.Model, similarity)
+	}
+	return nil
+}
+
+// fingerprintSimilarity returns the cosine similarity of two fingerprints.
+func fingerprintSimilarity(a, b string) (float64, error) {
+	va, err := parseFingerprint(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseFingerprint(b)
+	if err != nil {
+		return 0, err
+	}
+	if len(va) != len(vb) {
+		return 0, nil
+	}
+
+	var dot, na, nb float64
+	for i := range va {
+		dot += va[i] * vb[i]
+		na += va[i] * va[i]
+		nb += vb[i] * vb[i]
+	}
+	if na == 0 || nb == 0 {
+		if na == nb {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return dot / math.Sqrt(na*nb), nil
+}
+
+func parseFingerprint(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	values := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fingerprint %q: %w", s, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
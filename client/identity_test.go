@@ -0,0 +1,42 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityCheck(t *testing.T) {
+	probe := models.Embedding{0.1, -0.2, 0.3, 0.05}
+	stored := NewIdentity("ollama", "m", probe)
+	assert.Equal(t, 4, stored.Dimensions)
+	assert.Equal(t, "0.10000,-0.20000,0.30000,0.05000", stored.Fingerprint)
+
+	// Small provider noise is tolerated.
+	require.NoError(t, stored.Check(NewIdentity("ollama", "m", models.Embedding{0.1001, -0.2, 0.2999, 0.05})))
+
+	err := stored.Check(NewIdentity("ollama", "m", models.Embedding{0.3, 0.1, -0.2, 0.4}))
+	assert.ErrorIs(t, err, ErrModelMismatch)
+
+	err = stored.Check(NewIdentity("ollama", "m", models.Embedding{0.1, -0.2, 0.3}))
+	assert.ErrorIs(t, err, ErrModelMismatch)
+	assert.ErrorContains(t, err, "built with 4 dimensions")
+
+	// Nothing is known about indexes from before identities were stored.
+	require.NoError(t, Identity{}.Check(NewIdentity("ollama", "m", probe)))
+}
+
+func TestNewIdentityTruncatesFingerprint(t *testing.T) {
+	probe := make(models.Embedding, 1024)
+	identity := NewIdentity("litellm", "m", probe)
+	assert.Equal(t, 1024, identity.Dimensions)
+	assert.Len(t, parseFingerprintOrFail(t, identity.Fingerprint), fingerprintLength)
+}
+
+func parseFingerprintOrFail(t *testing.T, s string) []float64 {
+	values, err := parseFingerprint(s)
+	require.NoError(t, err)
+	return values
+}
@@ -0,0 +1,36 @@
+package client
+
+import "strings"
+
+// DefaultMaxInputTokens is the input budget assumed for unknown models.
+// It matches the default context of Ollama embedding models.
+const DefaultMaxInputTokens = 2048
+
+// knownMaxInputTokens lists input limits of common embedding models, matched
+// as a substring of the lowercased model name. More specific names go first.
+var knownMaxInputTokens = []struct {
+	model  string
+	tokens int
+}{
+	{"text-embedding-3", 8191},
+	{"text-embedding-ada-002", 8191},
+	{"codestral-embed", 8192},
+	{"mistral-embed", 8192},
+	{"voyage-code", 16000},
+	{"bge-m3", 8192},
+	{"nomic-embed", 2048},
+	{"mxbai-embed-large", 512},
+	{"all-minilm", 256},
+}
+
+// MaxInputTokens returns the approximate maximum number of input tokens model
+// accepts per input.
+func MaxInputTokens(model string) int {
+	name := strings.ToLower(model)
+	for _, known := range knownMaxInputTokens {
+		if strings.Contains(name, known.model) {
+			return known.tokens
+		}
+	}
+	return DefaultMaxInputTokens
+}
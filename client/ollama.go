@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	fastshot "github.com/opus-domini/fast-shot"
+)
+
+func init() {
+	Register(Provider{
+		Name:     "ollama",
+		Factory:  newOllama,
+		Defaults: Options{BaseURL: "http://localhost:11434"},
+	})
+}
+
+// Ollama embeds text using the Ollama /api/embed endpoint.
+type Ollama struct {
+	model string
+	http  fastshot.ClientHttpMethods
+	dims  dimensionProbe
+}
+
+func newOllama(model string, opts Options) Embedder {
+	return &Ollama{model: model, http: newHTTPClient(opts)}
+}
+
+func (o *Ollama) Embed(ctx context.Context, inputs []string) ([]models.Embedding, error) {
+	return postEmbeddings(ctx, o.http, "/api/embed", o.model, inputs)
+}
+
+func (o *Ollama) Dimensions(ctx context.Context) (int, error) {
+	return o.dims.get(ctx, o)
+}
+
+func (o *Ollama) Name() string {
+	return "ollama"
+}
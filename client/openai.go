@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	fastshot "github.com/opus-domini/fast-shot"
+)
+
+func init() {
+	Register(Provider{
+		Name:      "litellm",
+		Factory:   openAICompatibleFactory("litellm", "/v1/embeddings"),
+		Defaults:  Options{BaseURL: "http://localhost:4000", APIKey: "sk-1234"},
+		APIKeyEnv: []string{"LITELLM_API_KEY"},
+	})
+	Register(Provider{
+		Name:       "openai",
+		Factory:    openAICompatibleFactory("openai", "/embeddings"),
+		Defaults:   Options{BaseURL: "https://api.openai.com/v1"},
+		APIKeyEnv:  []string{"OPENAI_API_KEY"},
+		BaseURLEnv: []string{"OPENAI_BASE_URL"},
+	})
+}
+
+// OpenAICompatible embeds text using an OpenAI style embeddings endpoint.
+// It backs both the LiteLLM proxy and generic OpenAI compatible servers.
+type OpenAICompatible struct {
+	name  string
+	path  string
+	model string
+	http  fastshot.ClientHttpMethods
+	dims  dimensionProbe
+}
+
+func openAICompatibleFactory(name, path string) Factory {
+	return func(model string, opts Options) Embedder {
+		return &OpenAICompatible{name: name, path: path, model: model, http: newHTTPClient(opts)}
+	}
+}
+
+func (o *OpenAICompatible) Embed(ctx context.Context, inputs []string) ([]models.Embedding, error) {
+	return postEmbeddings(ctx, o.http, o.path, o.model, inputs)
+}
+
+func (o *OpenAICompatible) Dimensions(ctx context.Context) (int, error) {
+	return o.dims.get(ctx, o)
+}
+
+func (o *OpenAICompatible) Name() string {
+	return o.name
+}
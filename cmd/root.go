@@ -1,25 +1,118 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/andrejsstepanovs/codesearch/chunk"
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/file"
+	"github.com/andrejsstepanovs/codesearch/mcp"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/andrejsstepanovs/codesearch/project"
 	"github.com/andrejsstepanovs/codesearch/search"
+	"github.com/andrejsstepanovs/codesearch/server"
 	"github.com/andrejsstepanovs/codesearch/sync"
 	"github.com/spf13/cobra"
 )
 
-type App struct{}
+// Version is reported to MCP clients. Release builds set it with
+// -ldflags "-X github.com/andrejsstepanovs/codesearch/cmd.Version=<tag>".
+var Version = "dev"
+
+type App struct {
+	dataDir   string
+	baseURL   string
+	apiKeyEnv string
+	timeout   time.Duration
+
+	batchSize  int
+	batchBytes int
+	workers    int
+
+	chunkLines   int
+	chunkOverlap int
+	chunkTokens  int
+	maxTokens    int
+	exclude      []string
+
+	documentTemplate string
+	queryTemplate    string
+
+	maxFileSize      int64
+	includeGenerated bool
+	includeMinified  bool
+
+	fileLevel    bool
+	mode         string
+	vectorWeight float64
+	format       string
+	projects     []string
+	allProjects  bool
+	threshold    float64
+	neighbours   int
+	tuning       tuningFlags
+	reset        bool
+	paths        []string
+	extensions   []string
+	snippets     bool
+	context      int
+
+	yes bool
+
+	addr string
+}
+
+// clientOptions builds embedding client options from the global flags.
+func (a *App) clientOptions() (client.Options, error) {
+	opts := client.Options{
+		BaseURL: a.baseURL,
+		Timeout: a.timeout,
+	}
+	if a.apiKeyEnv != "" {
+		opts.APIKey = os.Getenv(a.apiKeyEnv)
+		if opts.APIKey == "" {
+			return client.Options{}, fmt.Errorf("environment variable %s is empty", a.apiKeyEnv)
+		}
+	}
+	return opts, nil
+}
 
 func newBuildCmd(app *App) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "build <project-alias> <project-path> [client-name] [model-name] [extensions]",
-		Short: "Build embeddings for a project. First argument is project alias, second is project path, optional third is client name (litellm, ollama), model name, optional fourth is comma separated list of file extensions (default: go,js,ts,py,java,cpp,c,h,hpp,yaml,yml)",
+		Short: "Build embeddings for a project. First argument is project alias, second is project path, optional third is client name (litellm, ollama, openai, hash), model name, optional fourth is comma separated list of file extensions (default: go,js,ts,py,java,cpp,c,h,hpp,yaml,yml)",
 		Run:   app.handleBuild,
 	}
+	addIndexFlags(cmd, app)
+	cmd.Flags().IntVar(&app.chunkLines, "chunk-lines", chunk.DefaultMaxLines, "Lines per chunk for non-Go files, 0 embeds whole files")
+	cmd.Flags().IntVar(&app.chunkOverlap, "chunk-overlap", chunk.DefaultOverlap, "Lines shared by adjacent chunks")
+	cmd.Flags().IntVar(&app.chunkTokens, "chunk-tokens", 0, "Approximate maximum tokens per chunk for non-Go files, 0 for no limit")
+	cmd.Flags().StringArrayVar(&app.exclude, "exclude", nil, "Gitignore-style patterns of files to skip, in addition to .gitignore and .codesearchignore (repeatable)")
+	cmd.Flags().Int64Var(&app.maxFileSize, "max-file-size", file.DefaultMaxSize, "Skip files larger than this many bytes, 0 for no limit")
+	cmd.Flags().BoolVar(&app.includeGenerated, "include-generated", false, "Index files marked \"Code generated ... DO NOT EDIT.\"")
+	cmd.Flags().BoolVar(&app.includeMinified, "include-minified", false, "Index minified files")
+	cmd.Flags().IntVar(&app.maxTokens, "max-tokens", 0, "Approximate model input limit in tokens, larger chunks are split (default: known limit of the model)")
+	cmd.Flags().StringVar(&app.documentTemplate, "document-template", "", "Text embedded for each chunk, with {path}, {language}, {symbol} and {content} placeholders, e.g. \"search_document: {content}\" (default: path and symbol followed by the content)")
+	cmd.Flags().StringVar(&app.queryTemplate, "query-template", "", "Text embedded for search queries, with a {query} placeholder, e.g. \"search_query: {query}\" (default: the query)")
 	return cmd
 }
 
+// addIndexFlags registers the flags shared by commands that generate embeddings.
+func addIndexFlags(cmd *cobra.Command, app *App) {
+	cmd.Flags().IntVar(&app.batchSize, "batch-size", sync.DefaultBatchSize, "Maximum number of files embedded per request")
+	cmd.Flags().IntVar(&app.batchBytes, "batch-bytes", sync.DefaultBatchBytes, "Maximum total input bytes embedded per request")
+	cmd.Flags().IntVar(&app.workers, "workers", sync.DefaultWorkers, "Number of files read and embedded concurrently")
+}
+
 func newSyncCmd(app *App) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "sync <project-alias>",
@@ -27,15 +120,163 @@ func newSyncCmd(app *App) *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Run:   app.handleSync,
 	}
+	addIndexFlags(cmd, app)
 	return cmd
 }
 
 func newSearchCmd(app *App) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "find <project-alias> <search-query>",
-		Short: "Search for code files in a project. First argument is project alias, rest are search query",
+		Use:   "find [project-alias] <search-query>",
+		Short: "Search for code files in a project. First argument is project alias, rest are search query. With --projects or --all all arguments are the query",
+		Args:  cobra.MinimumNArgs(1),
 		Run:   app.handleSearch,
 	}
+	cmd.Flags().StringSliceVar(&app.projects, "projects", nil, "Search these projects and merge the results, e.g. backend,frontend")
+	cmd.Flags().BoolVar(&app.allProjects, "all", false, "Search all registered projects and merge the results")
+	cmd.MarkFlagsMutuallyExclusive("projects", "all")
+	cmd.Flags().StringVar(&app.mode, "mode", search.ModeVector, "Search mode: "+strings.Join(search.Modes, ", ")+". Keyword and hybrid need a build with the sqlite_fts5 tag; hybrid falls back to vector without it")
+	cmd.Flags().Float64Var(&app.vectorWeight, "vector-weight", search.DefaultVectorWeight, "Share of the vector ranking in hybrid mode, between 0 (keyword only) and 1 (vector only)")
+	addResultFlags(cmd, app)
+	return cmd
+}
+
+func newSimilarCmd(app *App) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "similar <project-alias> <path>[:start-end]",
+		Short: "Find code similar to a file or a range of its lines, leaving out the file itself",
+		Args:  cobra.ExactArgs(2),
+		Run:   app.handleSimilar,
+	}
+	addResultFlags(cmd, app)
+	return cmd
+}
+
+func newDuplicatesCmd(app *App) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "duplicates <project-alias>",
+		Short: "Report clusters of near-identical code using the stored vectors",
+		Args:  cobra.ExactArgs(1),
+		Run:   app.handleDuplicates,
+	}
+	cmd.Flags().Float64Var(&app.threshold, "threshold", search.DefaultDuplicateThreshold, "Cosine similarity from which chunks count as near-duplicates")
+	cmd.Flags().IntVar(&app.neighbours, "neighbours", search.DefaultDuplicateNeighbours, "Nearest neighbours compared per chunk")
+	cmd.Flags().StringVar(&app.format, "format", search.FormatText, "Output format: text, json")
+	return cmd
+}
+
+// addResultFlags registers the flags selecting and printing results, shared
+// by find and similar.
+func addResultFlags(cmd *cobra.Command, app *App) {
+	cmd.Flags().BoolVar(&app.fileLevel, "files", false, "Aggregate chunk matches into one result per file")
+	app.tuning.register(cmd)
+	cmd.Flags().StringArrayVar(&app.paths, "path", nil, "Only return results under paths matching these gitignore-style patterns, e.g. 'internal/**' (repeatable)")
+	cmd.Flags().StringSliceVar(&app.extensions, "ext", nil, "Only return results with these file extensions, e.g. go,ts")
+	cmd.Flags().StringArrayVar(&app.exclude, "exclude", nil, "Drop results matching these gitignore-style patterns, e.g. '**/*_test.go' (repeatable)")
+	cmd.Flags().BoolVar(&app.snippets, "snippets", true, "Print the best matching lines of each result in text format")
+	cmd.Flags().IntVarP(&app.context, "context", "C", 2, "Lines of context around the best matching line of a snippet")
+	cmd.Flags().StringVar(&app.format, "format", search.FormatText, "Output format: "+strings.Join(search.Formats, ", "))
+}
+
+func newDefaultsCmd(app *App) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "defaults <project-alias>",
+		Short: "Show or change the find defaults of a project",
+		Long:  "Show or change the find defaults of a project. Only the given flags are changed; flags passed to find still take precedence.",
+		Args:  cobra.ExactArgs(1),
+		Run:   app.handleDefaults,
+	}
+	app.tuning.register(cmd)
+	cmd.Flags().BoolVar(&app.reset, "reset", false, "Clear the stored defaults before applying the given flags")
+	return cmd
+}
+
+// tuningFlags are the flags tuning how results are selected, shared by find
+// and defaults.
+type tuningFlags struct {
+	limit         int
+	minSimilarity float64
+	maxDistance   float64
+	minResults    int
+	adaptive      bool
+}
+
+func (f *tuningFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&f.limit, "limit", "n", search.DefaultLimit, "Maximum number of results")
+	cmd.Flags().Float64Var(&f.minSimilarity, "min-similarity", search.DefaultMinSimilarity, "Drop vector results with a lower cosine similarity")
+	cmd.Flags().Float64Var(&f.maxDistance, "max-distance", 1-search.DefaultMinSimilarity, "Drop vector results with a larger cosine distance; the stricter of this and --min-similarity applies")
+	cmd.Flags().IntVar(&f.minResults, "min-results", search.DefaultMinResults, "Vector results returned even when fewer pass the thresholds")
+	cmd.Flags().BoolVar(&f.adaptive, "adaptive", true, "Cut vector results at the largest gap in their distances")
+}
+
+// defaults returns the tuning flags set on the command line, so that unset
+// ones fall back to the project defaults.
+func (f *tuningFlags) defaults(cmd *cobra.Command) models.SearchDefaults {
+	var d models.SearchDefaults
+	flags := cmd.Flags()
+	if flags.Changed("limit") {
+		d.Limit = f.limit
+	}
+	if flags.Changed("min-similarity") {
+		d.MinSimilarity = &f.minSimilarity
+	}
+	if flags.Changed("max-distance") {
+		d.MaxDistance = &f.maxDistance
+	}
+	if flags.Changed("min-results") {
+		d.MinResults = &f.minResults
+	}
+	if flags.Changed("adaptive") {
+		d.Adaptive = &f.adaptive
+	}
+	return d
+}
+
+func newListCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List indexed projects",
+		Args:  cobra.NoArgs,
+		Run:   app.handleList,
+	}
+}
+
+func newInfoCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "info <project-alias>",
+		Short: "Show settings and index statistics of a project",
+		Args:  cobra.ExactArgs(1),
+		Run:   app.handleInfo,
+	}
+}
+
+func newRemoveCmd(app *App) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <project-alias>",
+		Short: "Delete the index of a project. Project sources are not touched",
+		Args:  cobra.ExactArgs(1),
+		Run:   app.handleRemove,
+	}
+	cmd.Flags().BoolVarP(&app.yes, "yes", "y", false, "Do not ask for confirmation")
+	return cmd
+}
+
+func newMCPCmd(app *App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "mcp",
+		Short: "Run a Model Context Protocol server over stdio exposing search, project listing, file snippets and sync as tools",
+		Args:  cobra.NoArgs,
+		Run:   app.handleMCP,
+	}
+}
+
+func newServeCmd(app *App) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run an HTTP JSON API for search, project listing and sync",
+		Args:  cobra.NoArgs,
+		Run:   app.handleServe,
+	}
+	cmd.Flags().StringVar(&app.addr, "addr", server.DefaultAddr, "Address to listen on")
 	return cmd
 }
 
@@ -43,11 +284,26 @@ func newRootCmd(app *App) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "codesearch",
 		Short: "CLI for managing code embeddings and search",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			db.SetDataDir(app.dataDir)
+		},
 	}
+	cmd.PersistentFlags().StringVar(&app.dataDir, "data-dir", "", "Directory holding the project registry and databases (default: "+db.DataDirEnv+" or ~/.local/share/codesearch)")
+	cmd.PersistentFlags().StringVar(&app.baseURL, "base-url", "", "Embedding provider base URL (default: CODESEARCH_<CLIENT>_BASE_URL or provider default)")
+	cmd.PersistentFlags().StringVar(&app.apiKeyEnv, "api-key-env", "", "Name of the environment variable holding the provider API key (default: CODESEARCH_<CLIENT>_API_KEY)")
+	cmd.PersistentFlags().DurationVar(&app.timeout, "timeout", 0, "Embedding request timeout (default: CODESEARCH_TIMEOUT or 1m)")
 	cmd.AddCommand(
 		newBuildCmd(app),
 		newSyncCmd(app),
 		newSearchCmd(app),
+		newSimilarCmd(app),
+		newDuplicatesCmd(app),
+		newListCmd(app),
+		newInfoCmd(app),
+		newDefaultsCmd(app),
+		newRemoveCmd(app),
+		newMCPCmd(app),
+		newServeCmd(app),
 	)
 	return cmd
 }
@@ -59,6 +315,28 @@ func (a *App) handleBuild(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	config.ClientOptions, err = a.clientOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	config.BatchSize = a.batchSize
+	config.BatchBytes = a.batchBytes
+	config.Workers = a.workers
+	config.Chunking.Lines = chunk.LineOptions{
+		MaxLines:  a.chunkLines,
+		Overlap:   a.chunkOverlap,
+		MaxTokens: a.chunkTokens,
+	}
+	config.MaxTokens = a.maxTokens
+	config.Templates = models.Templates{Document: a.documentTemplate, Query: a.queryTemplate}
+	config.Exclude = a.exclude
+	config.Filter = file.Filter{
+		MaxSize:          a.maxFileSize,
+		IncludeGenerated: a.includeGenerated,
+		IncludeMinified:  a.includeMinified,
+	}
+
 	if err := sync.Run(cmd.Context(), config); err != nil {
 		fmt.Printf("Error during build operation: %v\n", err)
 		os.Exit(1)
@@ -66,40 +344,415 @@ func (a *App) handleBuild(cmd *cobra.Command, args []string) {
 }
 
 func (a *App) handleSync(cmd *cobra.Command, args []string) {
-	projectAlias := args[0]
+	opts, err := a.clientOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config := &sync.Config{
+		ProjectAlias:  args[0],
+		ClientOptions: opts,
+		BatchSize:     a.batchSize,
+		BatchBytes:    a.batchBytes,
+		Workers:       a.workers,
+	}
 
-	if err := sync.RunSync(cmd.Context(), projectAlias); err != nil {
+	if err := sync.RunSync(cmd.Context(), config); err != nil {
 		fmt.Printf("Error during sync operation: %v\n", err)
 		os.Exit(1)
 	}
 }
 
 func (a *App) handleSearch(cmd *cobra.Command, args []string) {
-	config, err := search.ParseConfig(args)
+	// Only results go to stdout, so that it can be piped in any format.
+	stderr := cmd.ErrOrStderr()
+	multi := len(a.projects) > 0 || a.allProjects
+
+	var config *search.Config
+	var err error
+	if multi {
+		config, err = search.ParseQueryConfig(args)
+	} else {
+		config, err = search.ParseConfig(args)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := search.ValidateFormat(a.format); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config.ClientOptions, err = a.clientOptions()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	config.Mode = a.mode
+	config.VectorWeight = a.vectorWeight
+	a.applyResultFlags(cmd, config)
+
+	fmt.Fprintf(stderr, "Searching for: %s\n", config.Query)
+	var report search.Report
+	if multi {
+		report, err = a.searchProjects(cmd, config)
+	} else {
+		report, err = searchProject(cmd, config)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error during search operation: %v\n", err)
+		os.Exit(1)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(stderr, "Warning: %s\n", warning)
+	}
+	fmt.Fprintf(stderr, "Found %d results\n", len(report.Results))
+	a.writeReport(cmd, report)
+}
+
+// applyResultFlags sets the result selection flags on config.
+func (a *App) applyResultFlags(cmd *cobra.Command, config *search.Config) {
+	config.FileLevel = a.fileLevel
+	config.SearchDefaults = a.tuning.defaults(cmd)
+	config.Paths = a.paths
+	config.Extensions = a.extensions
+	config.Excludes = a.exclude
+}
+
+// writeReport prints report to stdout in the format of --format.
+func (a *App) writeReport(cmd *cobra.Command, report search.Report) {
+	report.Snippets = a.snippets
+	report.Context = a.context
+	report.Color = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	if err := search.Write(cmd.OutOrStdout(), a.format, report); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error writing results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (a *App) handleDuplicates(cmd *cobra.Command, args []string) {
+	stderr := cmd.ErrOrStderr()
+	if a.format != search.FormatText && a.format != search.FormatJSON {
+		fmt.Fprintf(stderr, "Error: unknown output format '%s', expected one of: text, json\n", a.format)
+		os.Exit(1)
+	}
+
+	opts, err := a.clientOptions()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	searcher, err := search.Open(args[0], opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer searcher.Close()
+
+	report, err := searcher.Duplicates(cmd.Context(), a.threshold, a.neighbours)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error finding duplicates: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(stderr, "Compared %d chunks, found %d clusters\n", report.Chunks, len(report.Clusters))
+
+	if err := search.WriteDuplicates(cmd.OutOrStdout(), a.format, report); err != nil {
+		fmt.Fprintf(stderr, "Error writing results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (a *App) handleSimilar(cmd *cobra.Command, args []string) {
+	stderr := cmd.ErrOrStderr()
+	target, err := search.ParseTarget(args[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := search.ValidateFormat(a.format); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config := &search.Config{ProjectAlias: args[0]}
+	config.ClientOptions, err = a.clientOptions()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	a.applyResultFlags(cmd, config)
+
+	searcher, err := search.Open(config.ProjectAlias, config.ClientOptions)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error during search operation: %v\n", err)
+		os.Exit(1)
+	}
+	defer searcher.Close()
+
+	fmt.Fprintf(stderr, "Searching for code similar to: %s\n", target)
+	results, err := searcher.Similar(cmd.Context(), target, config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error during search operation: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(stderr, "Found %d results\n", len(results))
+
+	a.writeReport(cmd, search.Report{
+		Project: config.ProjectAlias,
+		Root:    searcher.Project().Path,
+		Query:   target.String(),
+		Mode:    search.ModeVector,
+		Results: results,
+		// The query is a path, its words say nothing about the results.
+		Terms: []string{},
+	})
+}
+
+func searchProject(cmd *cobra.Command, config *search.Config) (search.Report, error) {
+	mode, err := search.ResolveMode(config.Mode)
+	if err != nil {
+		return search.Report{}, err
+	}
+
+	searcher, err := search.Open(config.ProjectAlias, config.ClientOptions)
+	if err != nil {
+		return search.Report{}, err
+	}
+	defer searcher.Close()
+
+	results, err := searcher.Search(cmd.Context(), config)
+	if err != nil {
+		return search.Report{}, err
+	}
+	report := search.Report{
+		Project: config.ProjectAlias,
+		Root:    searcher.Project().Path,
+		Query:   config.Query,
+		Mode:    mode,
+		Results: results,
+	}
+	if warning := search.FallbackWarning(config.Mode); warning != "" {
+		report.Warnings = append(report.Warnings, warning)
+	}
+	if warning := search.KeywordIndexWarning(searcher.Project(), mode); warning != "" {
+		report.Warnings = append(report.Warnings, warning)
+	}
+	return report, nil
+}
+
+// searchProjects searches the projects of --projects, or all registered
+// projects with --all.
+func (a *App) searchProjects(cmd *cobra.Command, config *search.Config) (search.Report, error) {
+	aliases := a.projects
+	if a.allProjects {
+		entries, err := db.ListProjects()
+		if err != nil {
+			return search.Report{}, err
+		}
+		aliases = nil
+		for _, entry := range entries {
+			aliases = append(aliases, entry.Alias)
+		}
+		if len(aliases) == 0 {
+			return search.Report{}, fmt.Errorf("no projects found")
+		}
+	}
+	return search.RunProjects(cmd.Context(), aliases, config)
+}
+
+func (a *App) handleList(cmd *cobra.Command, args []string) {
+	summaries, err := project.List()
+	if err != nil {
+		fmt.Printf("Error listing projects: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("No projects found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tPATH\tCLIENT\tMODEL\tEXTENSIONS\tFILES\tLAST SYNC\tSIZE")
+	for _, s := range summaries {
+		if s.Err != nil {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\terror: %v\n", s.Alias, s.Path, s.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", s.Alias, s.Path, s.Client, s.Model,
+			strings.Join(s.Extensions, ","), s.Stats.Files, formatTime(s.SyncedAt), project.FormatBytes(s.DatabaseSize))
+	}
+	w.Flush()
+}
+
+func (a *App) handleInfo(cmd *cobra.Command, args []string) {
+	s, err := project.Info(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Alias:\t%s\n", s.Alias)
+	fmt.Fprintf(w, "Path:\t%s\n", s.Path)
+	fmt.Fprintf(w, "Client:\t%s\n", s.Client)
+	fmt.Fprintf(w, "Model:\t%s\n", s.Model)
+	fmt.Fprintf(w, "Dimensions:\t%d\n", s.Dimensions)
+	fmt.Fprintf(w, "Extensions:\t%s\n", strings.Join(s.Extensions, ","))
+	if len(s.Exclude) > 0 {
+		fmt.Fprintf(w, "Exclude:\t%s\n", strings.Join(s.Exclude, " "))
+	}
+	fmt.Fprintf(w, "Chunking:\t%d lines, %d overlap, %d max tokens\n", s.ChunkLines, s.ChunkOverlap, s.ChunkTokens)
+	if s.MaxTokens > 0 {
+		fmt.Fprintf(w, "Model input limit:\t%d tokens\n", s.MaxTokens)
+	} else {
+		fmt.Fprintf(w, "Model input limit:\t%d tokens (model default)\n", client.MaxInputTokens(s.Model))
+	}
+	fmt.Fprintf(w, "Max file size:\t%d bytes\n", s.MaxFileSize)
+	fmt.Fprintf(w, "Generated files:\t%t\n", s.IncludeGenerated)
+	fmt.Fprintf(w, "Minified files:\t%t\n", s.IncludeMinified)
+	if s.Templates.Document != "" {
+		fmt.Fprintf(w, "Document template:\t%q\n", s.Templates.Document)
+	}
+	if s.Templates.Query != "" {
+		fmt.Fprintf(w, "Query template:\t%q\n", s.Templates.Query)
+	}
+	fmt.Fprintf(w, "Search defaults:\t%s\n", formatSearchDefaults(s.Search))
+	fmt.Fprintf(w, "Files:\t%d (%s)\n", s.Stats.Files, project.FormatBytes(s.Stats.SourceBytes))
+	fmt.Fprintf(w, "Chunks:\t%d\n", s.Stats.Chunks)
+	fmt.Fprintf(w, "Database:\t%s (%s)\n", s.Registry.Database, project.FormatBytes(s.DatabaseSize))
+	fmt.Fprintf(w, "Registered:\t%s\n", formatTime(s.Registry.CreatedAt))
+	fmt.Fprintf(w, "Last sync:\t%s\n", formatTime(s.SyncedAt))
+	w.Flush()
+}
+
+func (a *App) handleDefaults(cmd *cobra.Command, args []string) {
+	defaults := a.tuning.defaults(cmd)
+	if err := search.ValidateDefaults(defaults); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if defaults.IsZero() && !a.reset {
+		s, err := project.Info(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Search defaults of '%s': %s\n", args[0], formatSearchDefaults(s.Search))
+		return
+	}
+
+	stored, err := project.SetSearchDefaults(args[0], defaults, a.reset)
+	if err != nil {
+		fmt.Printf("Error storing search defaults: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Search defaults of '%s': %s\n", args[0], formatSearchDefaults(stored))
+}
+
+// formatSearchDefaults lists the stored search defaults, e.g. "limit 20,
+// min similarity 0.2".
+func formatSearchDefaults(d models.SearchDefaults) string {
+	var parts []string
+	if d.Limit != 0 {
+		parts = append(parts, fmt.Sprintf("limit %d", d.Limit))
+	}
+	if d.MinSimilarity != nil {
+		parts = append(parts, fmt.Sprintf("min similarity %g", *d.MinSimilarity))
+	}
+	if d.MaxDistance != nil {
+		parts = append(parts, fmt.Sprintf("max distance %g", *d.MaxDistance))
+	}
+	if d.MinResults != nil {
+		parts = append(parts, fmt.Sprintf("min results %d", *d.MinResults))
+	}
+	if d.Adaptive != nil {
+		parts = append(parts, fmt.Sprintf("adaptive %t", *d.Adaptive))
+	}
+	if len(parts) == 0 {
+		return "built-in"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (a *App) handleRemove(cmd *cobra.Command, args []string) {
+	alias := args[0]
+	entry, err := db.LookupProject(alias)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Searching for: %s\n", config.Query)
-	results, err := search.Run(cmd.Context(), config)
+	if !a.yes {
+		fmt.Printf("Delete %s, the index of project '%s' (%s)? Source files are not touched. [y/N] ", entry.Database, alias, entry.Path)
+		answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Aborted")
+			return
+		}
+	}
+
+	if err := project.Remove(alias); err != nil {
+		fmt.Printf("Error removing project: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Project '%s' removed\n", alias)
+}
+
+func (a *App) handleMCP(cmd *cobra.Command, args []string) {
+	opts, err := a.clientOptions()
 	if err != nil {
-		fmt.Printf("Error during search operation: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Found %d files\n", len(results))
-	for _, filePath := range results {
-		fmt.Printf("%s \t (%f %d)\n", filePath.File, filePath.Distance, filePath.ID)
+	server := mcp.NewServer("codesearch", Version, mcp.Tools(opts))
+	if err := server.Serve(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout()); err != nil && cmd.Context().Err() == nil {
+		fmt.Fprintf(os.Stderr, "Error running MCP server: %v\n", err)
+		os.Exit(1)
 	}
 }
 
+func (a *App) handleServe(cmd *cobra.Command, args []string) {
+	opts, err := a.clientOptions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Ctrl-C and SIGTERM cancel the command context, which shuts the server down gracefully.
+	if err := server.New(opts).ListenAndServe(cmd.Context(), a.addr); err != nil {
+		fmt.Printf("Error running server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
 // Execute initializes and runs the root command. It is the single entry point
 // for the command-line interface.
 func Execute() {
+	// Cancel the command context on Ctrl-C so running work stops cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	app := &App{}
 	rootCmd := newRootCmd(app)
-	err := rootCmd.Execute()
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
 		// Cobra prints the error, so we just need to exit.
 		os.Exit(1)
@@ -2,8 +2,14 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/andrejsstepanovs/codesearch/models"
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
@@ -18,170 +24,285 @@ func InitDB(name string, dimensions int) (*sql.DB, error) {
 		return nil, fmt.Errorf("error opening database: %w", err)
 	}
 
-	_, err = db.Exec(`
-				CREATE TABLE IF NOT EXISTS files (
-					id INTEGER PRIMARY KEY AUTOINCREMENT,
-					file TEXT,
-					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-				);
-			`)
+	err = migrate(db)
 	if err != nil {
-		return nil, fmt.Errorf("error creating files table: %w", err)
+		db.Close()
+		return nil, err
 	}
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS projects (
-			alias TEXT PRIMARY KEY NOT NULL,
-			path TEXT NOT NULL,
-			client TEXT NOT NULL,
-			model TEXT NOT NULL,
-			extensions TEXT NOT NULL DEFAULT ''
-		);
-	`)
+	_, err = db.Exec(vectorTableSQL("IF NOT EXISTS context_vectors", dimensions))
 	if err != nil {
-		return nil, fmt.Errorf("error creating projects table: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("error creating context_vectors table: %w", err)
 	}
 
-	_, err = db.Exec(`
-		CREATE VIRTUAL TABLE IF NOT EXISTS context_vectors USING vec0(
-			embedding float[` + fmt.Sprintf("%d", dimensions) + `],
-		);
-	`)
+	err = createKeywordIndex(db)
 	if err != nil {
-		return nil, fmt.Errorf("error creating context_vectors table: %w", err)
+		db.Close()
+		return nil, err
 	}
 
 	return db, nil
 }
 
 func SaveFileEmbedding(db *sql.DB, file string, embedding *models.Embedding) (int64, error) {
-	result, err := db.Exec("INSERT INTO files (file) VALUES (?)", file)
+	return SaveFile(db, models.File{File: file}, embedding)
+}
+
+// SaveFile stores a file record together with its content metadata and a
+// single embedding covering the whole file.
+func SaveFile(db *sql.DB, file models.File, embedding *models.Embedding) (int64, error) {
+	return SaveFileChunks(db, file, []models.Chunk{{}}, []models.Embedding{*embedding})
+}
+
+// SaveFileChunks stores a file record together with its chunks and their
+// embeddings, one embedding per chunk.
+func SaveFileChunks(db *sql.DB, file models.File, chunks []models.Chunk, embeddings []models.Embedding) (int64, error) {
+	var fileID int64
+	err := withTx(db, func(tx *sql.Tx) error {
+		var err error
+		fileID, err = insertFile(tx, file)
+		if err != nil {
+			return err
+		}
+		return insertChunks(tx, fileID, file.File, chunks, embeddings)
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert err: %w", err)
+		return 0, err
 	}
+	return fileID, nil
+}
 
-	lastID, err := result.LastInsertId()
+func UpdateFileEmbedding(db *sql.DB, fileID int64, filePath string, newEmbedding *models.Embedding) error {
+	return UpdateFile(db, fileID, models.File{File: filePath}, newEmbedding)
+}
+
+// UpdateFile replaces the record and embedding of fileID with file and newEmbedding.
+func UpdateFile(db *sql.DB, fileID int64, file models.File, newEmbedding *models.Embedding) error {
+	return ReplaceFileChunks(db, fileID, file, []models.Chunk{{}}, []models.Embedding{*newEmbedding})
+}
+
+// ReplaceFileChunks replaces the record, chunks and embeddings of fileID.
+// The file gets a new ID.
+func ReplaceFileChunks(db *sql.DB, fileID int64, file models.File, chunks []models.Chunk, embeddings []models.Embedding) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		err := deleteFile(tx, fileID)
+		if err != nil {
+			return err
+		}
+
+		newID, err := insertFile(tx, file)
+		if err != nil {
+			return err
+		}
+
+		return insertChunks(tx, newID, file.File, chunks, embeddings)
+	})
+}
+
+// GetFileChunks returns the chunks stored for fileID ordered by position.
+func GetFileChunks(db *sql.DB, fileID int64) ([]models.Chunk, error) {
+	rows, err := db.Query("SELECT id, file_id, symbol, start_line, end_line FROM chunks WHERE file_id = ? ORDER BY start_line, id", fileID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert id err: %w", err)
+		return nil, fmt.Errorf("failed to query chunks for fileID %d: %w", fileID, err)
 	}
+	defer rows.Close()
 
-	embeddingBytes, errSerialize := sqlite_vec.SerializeFloat32(embedding.Float32())
-	if errSerialize != nil {
-		return 0, fmt.Errorf("failed to serialize embedding: %w", errSerialize)
+	var chunks []models.Chunk
+	for rows.Next() {
+		var chunk models.Chunk
+		if err := rows.Scan(&chunk.ID, &chunk.FileID, &chunk.Symbol, &chunk.StartLine, &chunk.EndLine); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk row: %w", err)
+		}
+		chunks = append(chunks, chunk)
 	}
 
-	_, err = db.Exec("INSERT INTO context_vectors (rowid, embedding) VALUES (?, vec_f32(?))",
-		lastID,
-		embeddingBytes,
-	)
-	if err != nil {
-		return 0, fmt.Errorf("failed to insert into context_vectors: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during chunk row iteration: %w", err)
 	}
 
-	return lastID, nil
+	return chunks, nil
 }
 
-func UpdateFileEmbedding(db *sql.DB, fileID int64, filePath string, newEmbedding *models.Embedding) error {
+// withTx runs fn in a transaction, committing on success and rolling back on error.
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
 
-	// Delete the old vector first
-	_, err = tx.Exec("DELETE FROM context_vectors WHERE rowid = ?", fileID)
-	if err != nil {
-		return fmt.Errorf("failed to delete vector for fileID %d: %w", fileID, err)
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
 	}
 
-	// Delete the old file record
-	_, err = tx.Exec("DELETE FROM files WHERE id = ?", fileID)
-	if err != nil {
-		return fmt.Errorf("failed to delete file record for fileID %d: %w", fileID, err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Insert the new file record
-	result, err := tx.Exec("INSERT INTO files (file) VALUES (?)", filePath)
-	if err != nil {
-		return fmt.Errorf("failed to insert new file record: %w", err)
-	}
+	return nil
+}
 
-	newID, err := result.LastInsertId()
+func insertFile(tx *sql.Tx, file models.File) (int64, error) {
+	result, err := tx.Exec("INSERT INTO files (file, hash, size, mod_time) VALUES (?, ?, ?, ?)",
+		file.File,
+		file.Hash,
+		file.Size,
+		modTimeValue(file.ModTime),
+	)
 	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
+		return 0, fmt.Errorf("failed to insert file record: %w", err)
 	}
 
-	// Serialize the new embedding
-	embeddingBytes, err := sqlite_vec.SerializeFloat32(newEmbedding.Float32())
+	fileID, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("failed to serialize embedding: %w", err)
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
-	// Insert the new vector
-	_, err = tx.Exec("INSERT INTO context_vectors (rowid, embedding) VALUES (?, vec_f32(?))",
-		newID,
-		embeddingBytes,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to insert new vector: %w", err)
+	return fileID, nil
+}
+
+func insertChunks(tx *sql.Tx, fileID int64, path string, chunks []models.Chunk, embeddings []models.Embedding) error {
+	if len(chunks) != len(embeddings) {
+		return fmt.Errorf("got %d embeddings for %d chunks", len(embeddings), len(chunks))
 	}
 
-	// Commit the transaction
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	for i, chunk := range chunks {
+		result, err := tx.Exec("INSERT INTO chunks (file_id, symbol, start_line, end_line) VALUES (?, ?, ?, ?)",
+			fileID,
+			chunk.Symbol,
+			chunk.StartLine,
+			chunk.EndLine,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert chunk record: %w", err)
+		}
+
+		chunkID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+
+		embeddingBytes, err := sqlite_vec.SerializeFloat32(embeddings[i].Float32())
+		if err != nil {
+			return fmt.Errorf("failed to serialize embedding: %w", err)
+		}
+
+		_, err = tx.Exec("INSERT INTO context_vectors (rowid, embedding) VALUES (?, vec_f32(?))",
+			chunkID,
+			embeddingBytes,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert into context_vectors: %w", err)
+		}
+
+		err = insertKeywordChunk(tx, chunkID, path, chunk.Symbol, chunk.Content)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func DeleteFileAndVector(db *sql.DB, fileID int64) error {
-	tx, err := db.Begin()
+// deleteFile removes a file record with all its chunks and vectors.
+func deleteFile(tx *sql.Tx, fileID int64) error {
+	rows, err := tx.Query("SELECT id FROM chunks WHERE file_id = ?", fileID)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to query chunks for fileID %d: %w", fileID, err)
 	}
-	defer func() {
+
+	var chunkIDs []int64
+	for rows.Next() {
+		var chunkID int64
+		if err := rows.Scan(&chunkID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan chunk id: %w", err)
+		}
+		chunkIDs = append(chunkIDs, chunkID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during chunk id iteration: %w", err)
+	}
+
+	for _, chunkID := range chunkIDs {
+		_, err = tx.Exec("DELETE FROM context_vectors WHERE rowid = ?", chunkID)
 		if err != nil {
-			_ = tx.Rollback()
+			return fmt.Errorf("failed to delete vector for fileID %d: %w", fileID, err)
 		}
-	}()
 
-	// Delete the vector first
-	_, err = tx.Exec("DELETE FROM context_vectors WHERE rowid = ?", fileID)
+		err = deleteKeywordChunk(tx, chunkID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM chunks WHERE file_id = ?", fileID)
 	if err != nil {
-		return fmt.Errorf("failed to delete vector for fileID %d: %w", fileID, err)
+		return fmt.Errorf("failed to delete chunks for fileID %d: %w", fileID, err)
 	}
 
-	// Delete the file record
 	_, err = tx.Exec("DELETE FROM files WHERE id = ?", fileID)
 	if err != nil {
 		return fmt.Errorf("failed to delete file record for fileID %d: %w", fileID, err)
 	}
 
-	// Commit the transaction
-	err = tx.Commit()
+	return nil
+}
+
+// TouchFile refreshes the stored size and modification time of an unchanged file,
+// so the next sync can skip it without reading its content.
+func TouchFile(db *sql.DB, fileID int64, size int64, modTime time.Time) error {
+	_, err := db.Exec("UPDATE files SET size = ?, mod_time = ? WHERE id = ?", size, modTimeValue(modTime), fileID)
 	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return fmt.Errorf("failed to touch file record for fileID %d: %w", fileID, err)
 	}
-
 	return nil
 }
 
+func modTimeValue(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func DeleteFileAndVector(db *sql.DB, fileID int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		return deleteFile(tx, fileID)
+	})
+}
+
 func DeleteVectorData(db *sql.DB) error {
 	_, err := db.Exec("DELETE FROM files")
 	if err != nil {
 		return fmt.Errorf("failed to delete from files: %w", err)
 	}
 
+	_, err = db.Exec("DELETE FROM chunks")
+	if err != nil {
+		return fmt.Errorf("failed to delete from chunks: %w", err)
+	}
+
 	_, err = db.Exec("DELETE FROM context_vectors")
 	if err != nil {
 		return fmt.Errorf("failed to delete from context_vectors: %w", err)
 	}
 
+	if KeywordSearchAvailable() {
+		_, err = db.Exec("DELETE FROM chunks_fts")
+		if err != nil {
+			return fmt.Errorf("failed to delete from chunks_fts: %w", err)
+		}
+	}
+
+	// Both indexes are empty now; without FTS5 chunks_fts stops following.
+	_, err = db.Exec("UPDATE projects SET keyword_index = ?", KeywordSearchAvailable())
+	if err != nil {
+		return fmt.Errorf("failed to update keyword index state: %w", err)
+	}
+
 	return nil
 }
 
@@ -189,13 +310,26 @@ func DeleteAll(db *sql.DB) error {
 	return DeleteVectorData(db)
 }
 
+// UpsertProject stores the settings of a project. The model identity is not
+// stored, see SetProjectIdentity, so that it only changes once an index was
+// built with the new model.
 func UpsertProject(db *sql.DB, project models.Project) error {
 	query := `
-		INSERT INTO projects (alias, path, client, model, extensions) VALUES (?, ?, ?, ?, ?)
-		ON CONFLICT(alias) DO UPDATE SET path = excluded.path, client = excluded.client, model = excluded.model, extensions = excluded.extensions;
+		INSERT INTO projects (alias, path, client, model, extensions, chunk_lines, chunk_overlap, chunk_tokens, max_tokens, excludes,
+			max_file_size, include_generated, include_minified, document_template, query_template)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(alias) DO UPDATE SET path = excluded.path, client = excluded.client, model = excluded.model, extensions = excluded.extensions,
+			chunk_lines = excluded.chunk_lines, chunk_overlap = excluded.chunk_overlap, chunk_tokens = excluded.chunk_tokens,
+			max_tokens = excluded.max_tokens, excludes = excluded.excludes,
+			max_file_size = excluded.max_file_size, include_generated = excluded.include_generated, include_minified = excluded.include_minified,
+			document_template = excluded.document_template, query_template = excluded.query_template;
 	`
 	extensionsStr := strings.Join(project.Extensions, ",")
-	_, err := db.Exec(query, project.Alias, project.Path, project.Client, project.Model, extensionsStr)
+	_, err := db.Exec(query, project.Alias, project.Path, project.Client, project.Model, extensionsStr,
+		project.ChunkLines, project.ChunkOverlap, project.ChunkTokens, project.MaxTokens,
+		strings.Join(project.Exclude, "\n"),
+		project.MaxFileSize, project.IncludeGenerated, project.IncludeMinified,
+		project.Templates.Document, project.Templates.Query)
 	if err != nil {
 		return fmt.Errorf("failed to upsert project with alias '%s': %w", project.Alias, err)
 	}
@@ -204,13 +338,21 @@ func UpsertProject(db *sql.DB, project models.Project) error {
 
 func GetProjectByAlias(db *sql.DB, alias string) (*models.Project, error) {
 	query := `
-		SELECT alias, path, client, model, extensions FROM projects WHERE alias = ?
+		SELECT alias, path, client, model, extensions, chunk_lines, chunk_overlap, chunk_tokens, max_tokens, excludes,
+			max_file_size, include_generated, include_minified, synced_at, dimensions, fingerprint, search_defaults,
+			document_template, query_template, keyword_index
+		FROM projects WHERE alias = ?
 	`
 	row := db.QueryRow(query, alias)
 
 	var project models.Project
-	var extensionsStr string
-	err := row.Scan(&project.Alias, &project.Path, &project.Client, &project.Model, &extensionsStr)
+	var extensionsStr, excludesStr, searchDefaults string
+	var syncedAt int64
+	err := row.Scan(&project.Alias, &project.Path, &project.Client, &project.Model, &extensionsStr,
+		&project.ChunkLines, &project.ChunkOverlap, &project.ChunkTokens, &project.MaxTokens, &excludesStr,
+		&project.MaxFileSize, &project.IncludeGenerated, &project.IncludeMinified, &syncedAt,
+		&project.Dimensions, &project.Fingerprint, &searchDefaults,
+		&project.Templates.Document, &project.Templates.Query, &project.KeywordIndex)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err
@@ -224,19 +366,199 @@ func GetProjectByAlias(db *sql.DB, alias string) (*models.Project, error) {
 		project.Extensions = []string{}
 	}
 
+	// Patterns may contain commas, so they are stored one per line.
+	if excludesStr != "" {
+		project.Exclude = strings.Split(excludesStr, "\n")
+	}
+
+	if syncedAt != 0 {
+		project.SyncedAt = time.Unix(0, syncedAt)
+	}
+
+	if searchDefaults != "" {
+		if err := json.Unmarshal([]byte(searchDefaults), &project.Search); err != nil {
+			return nil, fmt.Errorf("failed to parse search defaults of project '%s': %w", alias, err)
+		}
+	}
+
 	return &project, nil
 }
 
+// MarkProjectSynced records when the last build or sync of a project finished.
+func MarkProjectSynced(db *sql.DB, alias string, t time.Time) error {
+	_, err := db.Exec("UPDATE projects SET synced_at = ? WHERE alias = ?", modTimeValue(t), alias)
+	if err != nil {
+		return fmt.Errorf("failed to mark project '%s' as synced: %w", alias, err)
+	}
+	return nil
+}
+
+// SetProjectIdentity records the dimensions and fingerprint of the model
+// that produced the vectors of a project.
+func SetProjectIdentity(db *sql.DB, alias string, dimensions int, fingerprint string) error {
+	_, err := db.Exec("UPDATE projects SET dimensions = ?, fingerprint = ? WHERE alias = ?", dimensions, fingerprint, alias)
+	if err != nil {
+		return fmt.Errorf("failed to store model identity of project '%s': %w", alias, err)
+	}
+	return nil
+}
+
+// SetSearchDefaults stores the find defaults of a project. Unlike the other
+// settings they are not replaced by UpsertProject, so they survive rebuilds.
+func SetSearchDefaults(db *sql.DB, alias string, defaults models.SearchDefaults) error {
+	value := ""
+	if !defaults.IsZero() {
+		encoded, err := json.Marshal(defaults)
+		if err != nil {
+			return fmt.Errorf("failed to encode search defaults: %w", err)
+		}
+		value = string(encoded)
+	}
+
+	res, err := db.Exec("UPDATE projects SET search_defaults = ? WHERE alias = ?", value, alias)
+	if err != nil {
+		return fmt.Errorf("failed to store search defaults of project '%s': %w", alias, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: '%s'", ErrProjectNotFound, alias)
+	}
+	return nil
+}
+
+var vectorDimensionsPattern = regexp.MustCompile(`float\[(\d+)\]`)
+
+// VectorDimensions returns the vector size the context_vectors table was
+// created with.
+func VectorDimensions(db *sql.DB) (int, error) {
+	var schema string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'context_vectors'").Scan(&schema)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read context_vectors schema: %w", err)
+	}
+
+	m := vectorDimensionsPattern.FindStringSubmatch(schema)
+	if m == nil {
+		return 0, fmt.Errorf("unexpected context_vectors schema: %s", schema)
+	}
+	return strconv.Atoi(m[1])
+}
+
+// ResizeVectors recreates the empty context_vectors table when it does not
+// hold vectors of dimensions, as vec0 tables have a fixed vector size. Any
+// vectors in it are dropped, so chunks must be deleted first.
+func ResizeVectors(db *sql.DB, dimensions int) error {
+	current, err := VectorDimensions(db)
+	if err != nil {
+		return err
+	}
+	if current == dimensions {
+		return nil
+	}
+
+	_, err = db.Exec(`DROP TABLE context_vectors`)
+	if err != nil {
+		return fmt.Errorf("failed to drop context_vectors table: %w", err)
+	}
+	_, err = db.Exec(vectorTableSQL("context_vectors", dimensions))
+	if err != nil {
+		return fmt.Errorf("error creating context_vectors table: %w", err)
+	}
+	return nil
+}
+
+// vectorTableSQL returns the statement creating the vec0 table name for
+// vectors of dimensions. Distances are cosine distances, 1 minus the cosine
+// similarity, instead of the L2 distances vec0 uses by default.
+func vectorTableSQL(name string, dimensions int) string {
+	return fmt.Sprintf(`
+		CREATE VIRTUAL TABLE %s USING vec0(
+			embedding float[%d] distance_metric=cosine
+		);
+	`, name, dimensions)
+}
+
+// Stats summarizes the contents of a project database.
+type Stats struct {
+	Files       int
+	Chunks      int
+	SourceBytes int64 // total size of the indexed files
+}
+
+// GetStats counts the indexed files and chunks.
+func GetStats(db *sql.DB) (Stats, error) {
+	var stats Stats
+	err := db.QueryRow("SELECT COUNT(*), COALESCE(SUM(size), 0) FROM files").Scan(&stats.Files, &stats.SourceBytes)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	err = db.QueryRow("SELECT COUNT(*) FROM chunks").Scan(&stats.Chunks)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to count chunks: %w", err)
+	}
+	return stats, nil
+}
+
+// SetupDatabase opens the database of a project in the data directory. A
+// positive dimensions creates it when missing; zero opens an existing index
+// and fails with ErrProjectNotFound otherwise.
 func SetupDatabase(projectAlias string, dimensions int) (*sql.DB, error) {
-	dbConn, err := InitDB(projectAlias, dimensions)
+	database, adopted, err := openProjectDatabase(projectAlias, dimensions > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dbConn, err := InitDB(strings.TrimSuffix(database, ".db"), dimensions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
+
+	if adopted {
+		// Register the moved database so it keeps resolving from any directory.
+		project, err := GetProjectByAlias(dbConn, projectAlias)
+		if err == nil {
+			err = RegisterProject(projectAlias, project.Path)
+		}
+		if err != nil {
+			dbConn.Close()
+			return nil, fmt.Errorf("failed to register moved database: %w", err)
+		}
+	}
+	return dbConn, nil
+}
+
+// CreateDatabase opens the database of a project to build it, creating it
+// when missing, and registers alias as indexing the sources at projectPath.
+// A database created by the call is removed again when either fails, so that
+// no database is left behind that list and remove cannot see.
+func CreateDatabase(projectAlias, projectPath string, dimensions int) (*sql.DB, error) {
+	database, _, err := openProjectDatabase(projectAlias, true)
+	if err != nil {
+		return nil, err
+	}
+	_, err = os.Stat(database)
+	created := os.IsNotExist(err)
+
+	dbConn, err := InitDB(strings.TrimSuffix(database, ".db"), dimensions)
+	if err != nil {
+		err = fmt.Errorf("failed to initialize database: %w", err)
+	} else if err = RegisterProject(projectAlias, projectPath); err != nil {
+		dbConn.Close()
+		err = fmt.Errorf("error registering project: %w", err)
+	}
+	if err != nil {
+		if created {
+			if removeErr := removeDatabaseFiles(database); removeErr != nil {
+				log.Printf("Error removing unregistered database: %v", removeErr)
+			}
+		}
+		return nil, err
+	}
 	return dbConn, nil
 }
 
 func GetFilesToSync(db *sql.DB) ([]models.File, error) {
-	query := `SELECT id, file, created_at FROM files ORDER BY created_at ASC`
+	query := `SELECT id, file, hash, size, mod_time, created_at FROM files ORDER BY created_at ASC`
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query files for sync: %w", err)
@@ -250,10 +572,14 @@ func GetFilesToSync(db *sql.DB) ([]models.File, error) {
 	var files []models.File
 	for rows.Next() {
 		var file models.File
+		var modTime int64
 		// The driver will handle DATETIME -> time.Time conversion
-		if err := rows.Scan(&file.ID, &file.File, &file.CreatedAt); err != nil {
+		if err := rows.Scan(&file.ID, &file.File, &file.Hash, &file.Size, &modTime, &file.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan file row: %w", err)
 		}
+		if modTime != 0 {
+			file.ModTime = time.Unix(0, modTime)
+		}
 		files = append(files, file)
 	}
 
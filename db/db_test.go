@@ -79,7 +79,8 @@ func TestGetProjectByAlias(t *testing.T) {
 	defer db.Close()
 
 	// Seed data
-	seedProject := models.Project{Alias: "test-proj", Path: "/path/to/test", Model: "test-model", Extensions: []string{".go", ".mod"}}
+	seedProject := models.Project{Alias: "test-proj", Path: "/path/to/test", Model: "test-model", Extensions: []string{".go", ".mod"},
+		Templates: models.Templates{Document: "search_document: {content}", Query: "search_query: {query}"}}
 	err = UpsertProject(db, seedProject)
 	require.NoError(t, err)
 
@@ -103,6 +104,7 @@ func TestGetProjectByAlias(t *testing.T) {
 				Path:       "/path/to/test",
 				Model:      "test-model",
 				Extensions: []string{".go", ".mod"},
+				Templates:  models.Templates{Document: "search_document: {content}", Query: "search_query: {query}"},
 			},
 		},
 		{
@@ -401,3 +403,210 @@ func TestDeleteFileAndVector(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, vectorCount)
 }
+
+func TestSaveFileMetadata(t *testing.T) {
+	deleteDbFile(t, "test_file_metadata.db")
+	db, err := InitDB("test_file_metadata", 4)
+	require.NoError(t, err)
+	defer db.Close()
+
+	embedding := models.Embedding{0.1, 0.2, 0.3, 0.4}
+	modTime := time.Date(2024, 5, 1, 12, 30, 0, 123, time.UTC)
+
+	fileID, err := SaveFile(db, models.File{File: "/a.go", Hash: "abc", Size: 42, ModTime: modTime}, &embedding)
+	require.NoError(t, err)
+
+	files, err := GetFilesToSync(db)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "abc", files[0].Hash)
+	assert.Equal(t, int64(42), files[0].Size)
+	assert.True(t, modTime.Equal(files[0].ModTime))
+
+	newModTime := modTime.Add(time.Hour)
+	err = TouchFile(db, fileID, 43, newModTime)
+	require.NoError(t, err)
+
+	files, err = GetFilesToSync(db)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "abc", files[0].Hash)
+	assert.Equal(t, int64(43), files[0].Size)
+	assert.True(t, newModTime.Equal(files[0].ModTime))
+
+	err = UpdateFile(db, fileID, models.File{File: "/a.go", Hash: "def", Size: 10, ModTime: newModTime}, &embedding)
+	require.NoError(t, err)
+
+	files, err = GetFilesToSync(db)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "def", files[0].Hash)
+	assert.Equal(t, int64(10), files[0].Size)
+}
+
+func TestInitDBAddsMissingFileColumns(t *testing.T) {
+	deleteDbFile(t, "test_legacy_files.db")
+
+	legacy, err := sql.Open("sqlite3", "test_legacy_files.db")
+	require.NoError(t, err)
+	_, err = legacy.Exec(`CREATE TABLE files (id INTEGER PRIMARY KEY AUTOINCREMENT, file TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`INSERT INTO files (file) VALUES ('/legacy.go')`)
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	db, err := InitDB("test_legacy_files", 4)
+	require.NoError(t, err)
+	defer db.Close()
+
+	files, err := GetFilesToSync(db)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/legacy.go", files[0].File)
+	assert.Equal(t, "", files[0].Hash)
+}
+
+func TestSaveFileChunks(t *testing.T) {
+	deleteDbFile(t, "test_file_chunks.db")
+	db, err := InitDB("test_file_chunks", 2)
+	require.NoError(t, err)
+	defer db.Close()
+
+	chunks := []models.Chunk{
+		{Symbol: "package demo", StartLine: 1, EndLine: 3},
+		{Symbol: "Handler", StartLine: 5, EndLine: 20},
+	}
+	embeddings := []models.Embedding{{1, 0}, {0, 1}}
+
+	fileID, err := SaveFileChunks(db, models.File{File: "/demo.go"}, chunks, embeddings)
+	require.NoError(t, err)
+
+	stored, err := GetFileChunks(db, fileID)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "Handler", stored[1].Symbol)
+	assert.Equal(t, 5, stored[1].StartLine)
+	assert.Equal(t, 20, stored[1].EndLine)
+
+	results, err := SearchWithThreshold(db, []float32{0, 1}, SearchOptions{MaxDistance: 2, MinResults: 1, MaxResults: 1})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "/demo.go", results[0].File)
+	assert.Equal(t, "Handler", results[0].Symbol)
+	assert.Equal(t, 5, results[0].StartLine)
+
+	err = ReplaceFileChunks(db, fileID, models.File{File: "/demo.go"}, chunks[:1], embeddings[:1])
+	require.NoError(t, err)
+
+	var chunkCount, vectorCount int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM chunks").Scan(&chunkCount))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM context_vectors").Scan(&vectorCount))
+	assert.Equal(t, 1, chunkCount)
+	assert.Equal(t, 1, vectorCount)
+
+	_, err = SaveFileChunks(db, models.File{File: "/bad.go"}, chunks, embeddings[:1])
+	assert.Error(t, err)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM files WHERE file = '/bad.go'").Scan(&chunkCount))
+	assert.Equal(t, 0, chunkCount) // rolled back
+}
+
+func TestInitDBBackfillsChunks(t *testing.T) {
+	deleteDbFile(t, "test_legacy_chunks.db")
+
+	// A database from before chunking: vectors keyed by file id.
+	legacy, err := InitDB("test_legacy_chunks", 2)
+	require.NoError(t, err)
+	_, err = legacy.Exec("DROP TABLE chunks; PRAGMA user_version = 2")
+	require.NoError(t, err)
+	_, err = legacy.Exec("INSERT INTO files (id, file) VALUES (7, '/legacy.go')")
+	require.NoError(t, err)
+	_, err = legacy.Exec("INSERT INTO context_vectors (rowid, embedding) VALUES (7, vec_f32('[1, 0]'))")
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	db, err := InitDB("test_legacy_chunks", 2)
+	require.NoError(t, err)
+	defer db.Close()
+
+	results, err := SearchWithThreshold(db, []float32{1, 0}, SearchOptions{MaxDistance: 2, MinResults: 1, MaxResults: 1})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "/legacy.go", results[0].File)
+
+	err = DeleteFileAndVector(db, 7)
+	require.NoError(t, err)
+
+	var vectorCount int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM context_vectors").Scan(&vectorCount))
+	assert.Equal(t, 0, vectorCount)
+}
+
+func TestAggregateByFile(t *testing.T) {
+	results := []SearchResult{
+		{ID: 1, File: "/a.go", Distance: 0.9, ChunkID: 10, Symbol: "A", StartLine: 5},
+		{ID: 2, File: "/b.py", Distance: 0.8, ChunkID: 20, StartLine: 1},
+		{ID: 1, File: "/a.go", Distance: 0.7, ChunkID: 11, Symbol: "B", StartLine: 30},
+		{ID: 3, File: "/c.ts", Distance: 0.6, ChunkID: 30, StartLine: 50},
+		{ID: 2, File: "/b.py", Distance: 0.5, ChunkID: 21, StartLine: 51},
+		{ID: 1, File: "/a.go", Distance: 0.4, ChunkID: 12, Symbol: "C", StartLine: 90},
+	}
+
+	files := AggregateByFile(results)
+	require.Len(t, files, 3)
+
+	assert.Equal(t, SearchResult{ID: 1, File: "/a.go", Distance: 0.9, Hits: 3}, files[0])
+	assert.Equal(t, SearchResult{ID: 2, File: "/b.py", Distance: 0.8, Hits: 2}, files[1])
+	assert.Equal(t, SearchResult{ID: 3, File: "/c.ts", Distance: 0.6, Hits: 1}, files[2])
+	assert.Equal(t, "/a.go", files[0].Location())
+}
+
+func TestProjectIndexSettings(t *testing.T) {
+	deleteDbFile(t, "test_project_chunks.db")
+	db, err := InitDB("test_project_chunks", 4)
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = UpsertProject(db, models.Project{Alias: "p", Path: "/p", Client: "ollama", Model: "m", ChunkLines: 40, ChunkOverlap: 5, ChunkTokens: 512, MaxTokens: 8192, Exclude: []string{"vendor/", "*.{a,b}"}, MaxFileSize: 4096, IncludeGenerated: true})
+	require.NoError(t, err)
+
+	project, err := GetProjectByAlias(db, "p")
+	require.NoError(t, err)
+	assert.Equal(t, 40, project.ChunkLines)
+	assert.Equal(t, 5, project.ChunkOverlap)
+	assert.Equal(t, 512, project.ChunkTokens)
+	assert.Equal(t, 8192, project.MaxTokens)
+	assert.Equal(t, []string{"vendor/", "*.{a,b}"}, project.Exclude)
+	assert.Equal(t, int64(4096), project.MaxFileSize)
+	assert.True(t, project.IncludeGenerated)
+	assert.False(t, project.IncludeMinified)
+}
+
+func TestSetSearchDefaults(t *testing.T) {
+	deleteDbFile(t, "test_search_defaults.db")
+	db, err := InitDB("test_search_defaults", 4)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, UpsertProject(db, models.Project{Alias: "p", Path: "/p", Client: "ollama", Model: "m"}))
+	project, err := GetProjectByAlias(db, "p")
+	require.NoError(t, err)
+	assert.True(t, project.Search.IsZero())
+
+	similarity, adaptive := 0.25, false
+	defaults := models.SearchDefaults{Limit: 20, MinSimilarity: &similarity, Adaptive: &adaptive}
+	require.NoError(t, SetSearchDefaults(db, "p", defaults))
+
+	// A rebuild upserts the project again and keeps the defaults.
+	require.NoError(t, UpsertProject(db, models.Project{Alias: "p", Path: "/p", Client: "ollama", Model: "m2"}))
+	project, err = GetProjectByAlias(db, "p")
+	require.NoError(t, err)
+	assert.Equal(t, defaults, project.Search)
+	assert.Nil(t, project.Search.MaxDistance)
+
+	require.NoError(t, SetSearchDefaults(db, "p", models.SearchDefaults{}))
+	project, err = GetProjectByAlias(db, "p")
+	require.NoError(t, err)
+	assert.True(t, project.Search.IsZero())
+
+	assert.ErrorIs(t, SetSearchDefaults(db, "missing", defaults), ErrProjectNotFound)
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// keywordIndex reports whether SQLite was compiled with FTS5, which requires
+// the sqlite_fts5 build tag. Without it only vector search is available.
+var keywordIndex struct {
+	once      sync.Once
+	available bool
+}
+
+// KeywordSearchAvailable reports whether keyword search is supported by this
+// build. The first call asks an in-memory database, so no project database
+// needs to be open.
+func KeywordSearchAvailable() bool {
+	keywordIndex.once.Do(func() {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			return
+		}
+		defer db.Close()
+		var used int
+		err = db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+		keywordIndex.available = err == nil && used == 1
+	})
+	return keywordIndex.available
+}
+
+// createKeywordIndex creates the FTS5 table over chunk contents when FTS5 is
+// available. Its rowid is the chunk id.
+func createKeywordIndex(db *sql.DB) error {
+	if !KeywordSearchAvailable() {
+		return nil
+	}
+
+	// Underscores are part of identifiers, so snake_case names stay one token.
+	_, err := db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS chunks_fts USING fts5(
+			file, symbol, content,
+			tokenize = "unicode61 tokenchars '_'"
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating chunks_fts table: %w", err)
+	}
+	return nil
+}
+
+func insertKeywordChunk(tx *sql.Tx, chunkID int64, file, symbol, content string) error {
+	if !keywordIndex.available {
+		return markKeywordIndexIncomplete(tx)
+	}
+	_, err := tx.Exec("INSERT INTO chunks_fts (rowid, file, symbol, content) VALUES (?, ?, ?, ?)", chunkID, file, symbol, content)
+	if err != nil {
+		return fmt.Errorf("failed to insert into chunks_fts: %w", err)
+	}
+	return nil
+}
+
+func deleteKeywordChunk(tx *sql.Tx, chunkID int64) error {
+	if !keywordIndex.available {
+		return markKeywordIndexIncomplete(tx)
+	}
+	_, err := tx.Exec("DELETE FROM chunks_fts WHERE rowid = ?", chunkID)
+	if err != nil {
+		return fmt.Errorf("failed to delete from chunks_fts: %w", err)
+	}
+	return nil
+}
+
+// markKeywordIndexIncomplete records that chunks changed without chunks_fts
+// following, in builds without FTS5.
+func markKeywordIndexIncomplete(tx *sql.Tx) error {
+	_, err := tx.Exec("UPDATE projects SET keyword_index = 0 WHERE keyword_index = 1")
+	if err != nil {
+		return fmt.Errorf("failed to mark keyword index as incomplete: %w", err)
+	}
+	return nil
+}
+
+// KeywordChunk is the text of a stored chunk for the keyword index.
+type KeywordChunk struct {
+	ChunkID int64
+	File    string
+	Symbol  string
+	Content string
+}
+
+// RebuildKeywordIndex replaces the contents of chunks_fts with chunks and
+// marks the keyword index of the project as complete. Chunks do not store
+// their content, so callers read it from the project sources.
+func RebuildKeywordIndex(db *sql.DB, chunks []KeywordChunk) error {
+	if !keywordIndex.available {
+		return fmt.Errorf("keyword search is not available: codesearch was built without the sqlite_fts5 build tag")
+	}
+
+	return withTx(db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM chunks_fts")
+		if err != nil {
+			return fmt.Errorf("failed to delete from chunks_fts: %w", err)
+		}
+		for _, c := range chunks {
+			if err := insertKeywordChunk(tx, c.ChunkID, c.File, c.Symbol, c.Content); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("UPDATE projects SET keyword_index = 1")
+		if err != nil {
+			return fmt.Errorf("failed to mark keyword index as complete: %w", err)
+		}
+		return nil
+	})
+}
+
+var keywordTermPattern = regexp.MustCompile(`[\pL\pN_]+`)
+
+// keywordQuery turns free text into an FTS5 query matching any of its terms.
+// Terms are quoted so that FTS5 operators in the input are taken literally.
+func keywordQuery(text string) string {
+	terms := keywordTermPattern.FindAllString(text, -1)
+	for i, term := range terms {
+		terms[i] = `"` + term + `"`
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// SearchKeyword ranks chunks by BM25 relevance to the terms of query. Matches
+// in symbols and paths weigh more than matches in content. Distance holds the
+// relevance, higher is better.
+func SearchKeyword(db *sql.DB, query string, maxResults int) ([]SearchResult, error) {
+	if !keywordIndex.available {
+		return nil, fmt.Errorf("keyword search is not available: codesearch was built without the sqlite_fts5 build tag")
+	}
+
+	match := keywordQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT f.id, f.file, c.id, c.symbol, c.start_line, c.end_line, bm25(chunks_fts, 2.0, 4.0, 1.0) AS score
+		FROM chunks_fts
+		JOIN chunks c ON c.id = chunks_fts.rowid
+		JOIN files f ON f.id = c.file_id
+		WHERE chunks_fts MATCH ?
+		ORDER BY score
+		LIMIT ?
+	`, match, maxResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute keyword search query: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		var score float64
+		err = rows.Scan(&result.ID, &result.File, &result.ChunkID, &result.Symbol, &result.StartLine, &result.EndLine, &score)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan keyword search row: %w", err)
+		}
+		// bm25 returns lower values for better matches.
+		result.Distance = -score
+		results = append(results, result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+	return results, nil
+}
+
+// rrfK dampens the influence of top ranks in reciprocal rank fusion; 60 is
+// the value from the original paper.
+const rrfK = 60
+
+// MaxFusedScore is the score FuseRankings gives a result ranked first by
+// both rankings.
+const MaxFusedScore = 1.0 / (rrfK + 1)
+
+// FuseRankings merges vector and keyword results, each sorted best first,
+// with reciprocal rank fusion. vectorWeight in [0, 1] is the share of the
+// vector ranking, the keyword ranking gets the rest. Distance holds the fused
+// score, higher is better.
+func FuseRankings(vector, keyword []SearchResult, vectorWeight float64) []SearchResult {
+	scores := make(map[int64]float64)
+	byChunk := make(map[int64]SearchResult)
+	add := func(results []SearchResult, weight float64) {
+		for rank, result := range results {
+			scores[result.ChunkID] += weight / float64(rrfK+rank+1)
+			if _, ok := byChunk[result.ChunkID]; !ok {
+				byChunk[result.ChunkID] = result
+			}
+		}
+	}
+	add(vector, vectorWeight)
+	add(keyword, 1-vectorWeight)
+
+	fused := make([]SearchResult, 0, len(byChunk))
+	for chunkID, result := range byChunk {
+		result.Distance = scores[chunkID]
+		fused = append(fused, result)
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		if fused[i].Distance != fused[j].Distance {
+			return fused[i].Distance > fused[j].Distance
+		}
+		return fused[i].ChunkID < fused[j].ChunkID
+	})
+	return fused
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordQuery(t *testing.T) {
+	assert.Equal(t, `"handleBuild"`, keywordQuery("handleBuild"))
+	assert.Equal(t, `"failed" OR "to" OR "open" OR "db_conn"`, keywordQuery(`failed to "open" db_conn*`))
+	assert.Equal(t, "", keywordQuery("  -- "))
+}
+
+func TestFuseRankings(t *testing.T) {
+	distance := 0.4
+	vector := []SearchResult{{ChunkID: 1}, {ChunkID: 2}, {ChunkID: 3, VectorDistance: &distance}}
+	keyword := []SearchResult{{ChunkID: 3}, {ChunkID: 4}}
+
+	fused := FuseRankings(vector, keyword, 0.5)
+	ids := make([]int64, len(fused))
+	for i, r := range fused {
+		ids[i] = r.ChunkID
+	}
+	// Chunk 3 is found by both rankings.
+	assert.Equal(t, []int64{3, 1, 2, 4}, ids)
+	require.NotNil(t, fused[0].VectorDistance, "the vector distance is kept")
+	assert.Equal(t, 0.4, *fused[0].VectorDistance)
+	assert.Nil(t, fused[3].VectorDistance)
+
+	fused = FuseRankings(vector, keyword, 0)
+	assert.Equal(t, int64(3), fused[0].ChunkID)
+	assert.Equal(t, int64(4), fused[1].ChunkID)
+	assert.Zero(t, fused[2].Distance)
+}
+
+func TestSearchKeyword(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "keyword"), 2)
+	require.NoError(t, err)
+	defer db.Close()
+
+	if !KeywordSearchAvailable() {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+
+	_, err = SaveFileChunks(db, models.File{File: "/cmd/root.go"}, []models.Chunk{
+		{Symbol: "(*App).handleBuild", StartLine: 10, EndLine: 20, Content: "func (a *App) handleBuild() {\n\tsync.Run()\n}"},
+		{Symbol: "(*App).handleSync", StartLine: 22, EndLine: 30, Content: "func (a *App) handleSync() {\n\treturn errors.New(\"project not found\")\n}"},
+	}, []models.Embedding{{1, 0}, {0, 1}})
+	require.NoError(t, err)
+	fileID, err := SaveFileChunks(db, models.File{File: "/README.md"}, []models.Chunk{{Content: "run handleBuild via make"}}, []models.Embedding{{1, 1}})
+	require.NoError(t, err)
+
+	results, err := SearchKeyword(db, "handleBuild", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "(*App).handleBuild", results[0].Symbol)
+	assert.Equal(t, "/README.md", results[1].File)
+	assert.Greater(t, results[0].Distance, results[1].Distance)
+
+	results, err = SearchKeyword(db, `"project not found"`, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "(*App).handleSync", results[0].Symbol)
+
+	// Deleted files leave the keyword index.
+	require.NoError(t, DeleteFileAndVector(db, fileID))
+	results, err = SearchKeyword(db, "make", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	require.NoError(t, DeleteVectorData(db))
+	results, err = SearchKeyword(db, "handleBuild", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestKeywordIndexState(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "state"), 2)
+	require.NoError(t, err)
+	defer db.Close()
+
+	if !KeywordSearchAvailable() {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+
+	require.NoError(t, UpsertProject(db, models.Project{Alias: "state", Path: "/src"}))
+	keywordIndex := func() bool {
+		project, err := GetProjectByAlias(db, "state")
+		require.NoError(t, err)
+		return project.KeywordIndex
+	}
+	assert.False(t, keywordIndex(), "unknown until the project is built")
+
+	require.NoError(t, DeleteVectorData(db))
+	assert.True(t, keywordIndex())
+
+	// A build without FTS5 changes chunks without the keyword index.
+	previous := keywordIndexAvailable(false)
+	_, err = SaveFileChunks(db, models.File{File: "/a.go"}, []models.Chunk{{Symbol: "parseConfig", StartLine: 1, EndLine: 3}}, []models.Embedding{{1, 0}})
+	keywordIndexAvailable(previous)
+	require.NoError(t, err)
+	assert.False(t, keywordIndex())
+
+	results, err := SearchKeyword(db, "parseConfig", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	ids, err := GetChunkIDs(db)
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+	require.NoError(t, RebuildKeywordIndex(db, []KeywordChunk{{ChunkID: ids[0], File: "/a.go", Symbol: "parseConfig", Content: "func parseConfig() {}"}}))
+	assert.True(t, keywordIndex())
+
+	results, err = SearchKeyword(db, "parseConfig", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "/a.go", results[0].File)
+}
+
+// keywordIndexAvailable overrides whether FTS5 is used and returns the
+// previous value.
+func keywordIndexAvailable(available bool) bool {
+	previous := keywordIndex.available
+	keywordIndex.available = available
+	return previous
+}
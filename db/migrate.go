@@ -0,0 +1,260 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// migration upgrades a database by one schema version.
+type migration struct {
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations are applied in order; the schema version of a database, stored
+// in PRAGMA user_version, is the number of migrations applied to it. Append
+// new migrations, never change released ones.
+//
+// Databases created before versioning have user_version 0 but may already
+// contain some of these changes, so migrations must tolerate that.
+var migrations = []migration{
+	{"create files and projects tables", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS files (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				file TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS projects (
+				alias TEXT PRIMARY KEY NOT NULL,
+				path TEXT NOT NULL,
+				client TEXT NOT NULL,
+				model TEXT NOT NULL,
+				extensions TEXT NOT NULL DEFAULT ''
+			);
+		`)
+		return err
+	}},
+	{"store file hash, size and modification time", func(tx *sql.Tx) error {
+		return addColumns(tx, "files", []column{
+			{"hash", "TEXT NOT NULL DEFAULT ''"},
+			{"size", "INTEGER NOT NULL DEFAULT 0"},
+			{"mod_time", "INTEGER NOT NULL DEFAULT 0"},
+		})
+	}},
+	{"split files into chunks", createChunksTable},
+	{"store chunking settings and excludes", func(tx *sql.Tx) error {
+		// Projects built before line-window chunking keep whole-file chunks.
+		return addColumns(tx, "projects", []column{
+			{"chunk_lines", "INTEGER NOT NULL DEFAULT 0"},
+			{"chunk_overlap", "INTEGER NOT NULL DEFAULT 0"},
+			{"chunk_tokens", "INTEGER NOT NULL DEFAULT 0"},
+			{"max_tokens", "INTEGER NOT NULL DEFAULT 0"},
+			{"excludes", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+	{"store file filter settings", func(tx *sql.Tx) error {
+		return addColumns(tx, "projects", []column{
+			{"max_file_size", "INTEGER NOT NULL DEFAULT 0"},
+			{"include_generated", "BOOLEAN NOT NULL DEFAULT 0"},
+			{"include_minified", "BOOLEAN NOT NULL DEFAULT 0"},
+		})
+	}},
+	{"store last sync time", func(tx *sql.Tx) error {
+		return addColumns(tx, "projects", []column{
+			{"synced_at", "INTEGER NOT NULL DEFAULT 0"},
+		})
+	}},
+	{"store model dimensions and fingerprint", func(tx *sql.Tx) error {
+		return addColumns(tx, "projects", []column{
+			{"dimensions", "INTEGER NOT NULL DEFAULT 0"},
+			{"fingerprint", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+	{"store search defaults", func(tx *sql.Tx) error {
+		return addColumns(tx, "projects", []column{
+			{"search_defaults", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+	{"store embedding templates", func(tx *sql.Tx) error {
+		return addColumns(tx, "projects", []column{
+			{"document_template", "TEXT NOT NULL DEFAULT ''"},
+			{"query_template", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+	{"record whether the keyword index is complete", func(tx *sql.Tx) error {
+		err := addColumns(tx, "projects", []column{
+			{"keyword_index", "BOOLEAN NOT NULL DEFAULT 0"},
+		})
+		if err != nil {
+			return err
+		}
+		// Databases indexed before chunks_fts existed, or by builds without
+		// FTS5, cannot be told apart from complete ones by the schema alone.
+		// Without the table, or without FTS5 to read it, the flag stays unset
+		// and the next sync rebuilds the keyword index.
+		var complete bool
+		err = tx.QueryRow("SELECT (SELECT COUNT(*) FROM chunks_fts) = (SELECT COUNT(*) FROM chunks)").Scan(&complete)
+		if err != nil || !complete {
+			return nil
+		}
+		_, err = tx.Exec("UPDATE projects SET keyword_index = 1")
+		return err
+	}},
+	{"measure vector distances by cosine", cosineVectorTable},
+}
+
+// SchemaVersion is the schema version of databases created by this build.
+var SchemaVersion = len(migrations)
+
+// migrate upgrades db to SchemaVersion. Each migration runs in its own
+// transaction together with the version bump, so a failure leaves the
+// database at the last completed version.
+func migrate(db *sql.DB) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d, upgrade codesearch", version, SchemaVersion)
+	}
+
+	for i := version; i < SchemaVersion; i++ {
+		m := migrations[i]
+		err := withTx(db, func(tx *sql.Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate database to version %d (%s): %w", i+1, m.description, err)
+		}
+	}
+	return nil
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// createChunksTable creates the chunks table. Vectors are keyed by chunk id.
+// Databases built before chunking stored one vector per file keyed by file id,
+// so their files are backfilled as whole-file chunks with the same ids.
+func createChunksTable(tx *sql.Tx) error {
+	var exists int
+	err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'chunks'").Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking chunks table: %w", err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		CREATE TABLE chunks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			file_id INTEGER NOT NULL,
+			symbol TEXT NOT NULL DEFAULT '',
+			start_line INTEGER NOT NULL DEFAULT 0,
+			end_line INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE INDEX idx_chunks_file_id ON chunks (file_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating chunks table: %w", err)
+	}
+
+	_, err = tx.Exec("INSERT INTO chunks (id, file_id) SELECT id, id FROM files")
+	if err != nil {
+		return fmt.Errorf("error backfilling chunks table: %w", err)
+	}
+	return nil
+}
+
+// cosineVectorTable recreates a context_vectors table created with the L2
+// distance of vec0 with the cosine distance, keeping its vectors. Databases
+// without the table get it from InitDB.
+func cosineVectorTable(tx *sql.Tx) error {
+	var schema string
+	err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'context_vectors'").Scan(&schema)
+	if err == sql.ErrNoRows || strings.Contains(schema, "distance_metric=cosine") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read context_vectors schema: %w", err)
+	}
+	m := vectorDimensionsPattern.FindStringSubmatch(schema)
+	if m == nil {
+		return fmt.Errorf("unexpected context_vectors schema: %s", schema)
+	}
+	dimensions, err := strconv.Atoi(m[1])
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range []string{
+		"CREATE TEMP TABLE context_vectors_copy AS SELECT rowid AS id, embedding FROM context_vectors",
+		"DROP TABLE context_vectors",
+		vectorTableSQL("context_vectors", dimensions),
+		"INSERT INTO context_vectors (rowid, embedding) SELECT id, embedding FROM context_vectors_copy",
+		"DROP TABLE context_vectors_copy",
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to recreate context_vectors table: %w", err)
+		}
+	}
+	return nil
+}
+
+type column struct {
+	name       string
+	definition string
+}
+
+// addColumns adds any of the given columns that are missing from table.
+func addColumns(tx *sql.Tx, table string, columns []column) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read table info for %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return fmt.Errorf("failed to scan table info for %s: %w", table, err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during table info iteration for %s: %w", table, err)
+	}
+	rows.Close() // release the connection of tx before altering the table
+
+	for _, c := range columns {
+		if existing[c.name] {
+			continue
+		}
+		_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, c.name, c.definition))
+		if err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", table, c.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openFixture creates a database from an SQL script in testdata and returns
+// its name as accepted by InitDB.
+func openFixture(t *testing.T, script string) string {
+	schema, err := os.ReadFile(filepath.Join("testdata", script))
+	require.NoError(t, err)
+
+	name := filepath.Join(t.TempDir(), "fixture")
+	sqlite_vec.Auto()
+	fixture, err := sql.Open("sqlite3", name+".db")
+	require.NoError(t, err)
+	_, err = fixture.Exec(string(schema))
+	require.NoError(t, err)
+	require.NoError(t, fixture.Close())
+	return name
+}
+
+func TestMigrateV1Fixture(t *testing.T) {
+	name := openFixture(t, "v1.sql")
+
+	db, err := InitDB(name, 4)
+	require.NoError(t, err)
+	defer db.Close()
+
+	version, err := schemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+
+	project, err := GetProjectByAlias(db, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "/src/legacy", project.Path)
+	assert.Equal(t, []string{"go", "md"}, project.Extensions)
+	assert.Zero(t, project.ChunkLines)
+	assert.Zero(t, project.MaxFileSize)
+
+	files, err := GetFilesToSync(db)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Empty(t, files[0].Hash)
+
+	// Old vectors stay searchable as whole-file chunks.
+	results, err := SearchWithSimilarity(db, []float32{1, 0, 0, 0}, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "/main.go", results[0].File)
+	assert.Equal(t, int64(1), results[0].ChunkID)
+
+	// New files use the migrated schema.
+	_, err = SaveFileChunks(db, models.File{File: "/new.go", Hash: "h"}, []models.Chunk{{Symbol: "New", StartLine: 1, EndLine: 3}}, []models.Embedding{{0, 0, 1, 0}})
+	require.NoError(t, err)
+}
+
+func TestMigrateCosineDistance(t *testing.T) {
+	name := openFixture(t, "v1.sql")
+
+	db, err := InitDB(name, 4)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The L2 table of the fixture is recreated with its vectors, so that
+	// orthogonal vectors have a similarity of 0 instead of 1 - sqrt(2).
+	results, err := SearchSimilar(db, []float32{0, 1, 0, 0}, SearchOptions{MaxDistance: 2, MaxResults: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "/README.md", results[0].File)
+	assert.InDelta(t, 1, results[0].Distance, 1e-6)
+	assert.InDelta(t, 0, results[1].Distance, 1e-6)
+
+	dimensions, err := VectorDimensions(db)
+	require.NoError(t, err)
+	assert.Equal(t, 4, dimensions)
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "fresh")
+
+	db, err := InitDB(name, 4)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = InitDB(name, 4)
+	require.NoError(t, err)
+	defer db.Close()
+
+	version, err := schemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "newer")
+
+	db, err := InitDB(name, 4)
+	require.NoError(t, err)
+	_, err = db.Exec("PRAGMA user_version = 999")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	_, err = InitDB(name, 4)
+	assert.ErrorContains(t, err, "newer than supported")
+}
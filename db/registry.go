@@ -0,0 +1,281 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DataDirEnv is the environment variable overriding the data directory.
+const DataDirEnv = "CODESEARCH_DATA_DIR"
+
+// ErrProjectNotFound is returned when an alias has no index yet.
+var ErrProjectNotFound = errors.New("project not found")
+
+var dataDirOverride string
+
+// SetDataDir overrides where the registry and project databases are stored.
+// An empty dir restores the default: CODESEARCH_DATA_DIR, then
+// $XDG_DATA_HOME/codesearch, then ~/.local/share/codesearch.
+func SetDataDir(dir string) {
+	dataDirOverride = dir
+}
+
+// DataDir returns the directory holding the registry and project databases,
+// creating it if needed.
+func DataDir() (string, error) {
+	dir := dataDirOverride
+	if dir == "" {
+		dir = os.Getenv(DataDirEnv)
+	}
+	if dir == "" {
+		base := os.Getenv("XDG_DATA_HOME")
+		if base == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to find home directory: %w", err)
+			}
+			base = filepath.Join(home, ".local", "share")
+		}
+		dir = filepath.Join(base, "codesearch")
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create data directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// RegistryEntry records where a project and its database live.
+type RegistryEntry struct {
+	Alias     string
+	Path      string // project source directory
+	Database  string // database file
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OpenRegistry opens the registry database in the data directory.
+func OpenRegistry() (*sql.DB, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	reg, err := sql.Open("sqlite3", filepath.Join(dir, "registry.db"))
+	if err != nil {
+		return nil, fmt.Errorf("error opening registry: %w", err)
+	}
+
+	_, err = reg.Exec(`
+		CREATE TABLE IF NOT EXISTS projects (
+			alias TEXT PRIMARY KEY NOT NULL,
+			path TEXT NOT NULL DEFAULT '',
+			database TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		reg.Close()
+		return nil, fmt.Errorf("error creating registry table: %w", err)
+	}
+	return reg, nil
+}
+
+// validateAlias rejects aliases that cannot be used as a database file name.
+func validateAlias(alias string) error {
+	if alias == "" || alias == "." || alias == ".." || strings.ContainsAny(alias, `/\`) {
+		return fmt.Errorf("invalid project alias '%s'", alias)
+	}
+	return nil
+}
+
+// RegisterProject records that alias indexes the sources at projectPath.
+// The database location of an already registered alias is kept.
+func RegisterProject(alias, projectPath string) error {
+	reg, err := OpenRegistry()
+	if err != nil {
+		return err
+	}
+	defer reg.Close()
+
+	database, _, err := projectDatabase(reg, alias)
+	if err != nil {
+		return err
+	}
+
+	_, err = reg.Exec(`
+		INSERT INTO projects (alias, path, database) VALUES (?, ?, ?)
+		ON CONFLICT(alias) DO UPDATE SET path = excluded.path, updated_at = CURRENT_TIMESTAMP;
+	`, alias, projectPath, database)
+	if err != nil {
+		return fmt.Errorf("failed to register project '%s': %w", alias, err)
+	}
+	return nil
+}
+
+// LookupProject returns the registry entry of alias, or ErrProjectNotFound.
+func LookupProject(alias string) (RegistryEntry, error) {
+	reg, err := OpenRegistry()
+	if err != nil {
+		return RegistryEntry{}, err
+	}
+	defer reg.Close()
+
+	var entry RegistryEntry
+	err = reg.QueryRow("SELECT alias, path, database, created_at, updated_at FROM projects WHERE alias = ?", alias).
+		Scan(&entry.Alias, &entry.Path, &entry.Database, &entry.CreatedAt, &entry.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RegistryEntry{}, fmt.Errorf("%w: '%s'", ErrProjectNotFound, alias)
+	}
+	if err != nil {
+		return RegistryEntry{}, fmt.Errorf("failed to look up project '%s': %w", alias, err)
+	}
+	return entry, nil
+}
+
+// ListProjects returns all registered projects ordered by alias.
+func ListProjects() ([]RegistryEntry, error) {
+	reg, err := OpenRegistry()
+	if err != nil {
+		return nil, err
+	}
+	defer reg.Close()
+
+	rows, err := reg.Query("SELECT alias, path, database, created_at, updated_at FROM projects ORDER BY alias")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []RegistryEntry
+	for rows.Next() {
+		var entry RegistryEntry
+		err := rows.Scan(&entry.Alias, &entry.Path, &entry.Database, &entry.CreatedAt, &entry.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project row: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during project row iteration: %w", err)
+	}
+	return entries, nil
+}
+
+// RemoveProject deletes the database of alias and its registry entry. Only
+// files inside the data directory are removed, never the project sources.
+func RemoveProject(alias string) error {
+	entry, err := LookupProject(alias)
+	if err != nil {
+		return err
+	}
+
+	dir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(dir, entry.Database)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || !strings.HasSuffix(entry.Database, ".db") {
+		return fmt.Errorf("refusing to delete %s outside of the data directory %s", entry.Database, dir)
+	}
+
+	err = removeDatabaseFiles(entry.Database)
+	if err != nil {
+		return err
+	}
+
+	reg, err := OpenRegistry()
+	if err != nil {
+		return err
+	}
+	defer reg.Close()
+
+	_, err = reg.Exec("DELETE FROM projects WHERE alias = ?", alias)
+	if err != nil {
+		return fmt.Errorf("failed to unregister project '%s': %w", alias, err)
+	}
+	return nil
+}
+
+// removeDatabaseFiles deletes the database file and the journal or WAL SQLite
+// may keep next to it.
+func removeDatabaseFiles(database string) error {
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		err := os.Remove(database + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", database+suffix, err)
+		}
+	}
+	return nil
+}
+
+// projectDatabase returns the database file of alias. Unregistered aliases get
+// a file named after the alias in the projects directory of the data directory.
+func projectDatabase(reg *sql.DB, alias string) (database string, registered bool, err error) {
+	err = validateAlias(alias)
+	if err != nil {
+		return "", false, err
+	}
+
+	err = reg.QueryRow("SELECT database FROM projects WHERE alias = ?", alias).Scan(&database)
+	if err == nil {
+		return database, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", false, fmt.Errorf("failed to look up project '%s': %w", alias, err)
+	}
+
+	dir, err := DataDir()
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Join(dir, "projects", alias+".db"), false, nil
+}
+
+// openProjectDatabase resolves the database file of alias. Unless create is
+// set, the alias must either be registered or have a database from before the
+// registry existed in the working directory, which is then moved into place.
+func openProjectDatabase(alias string, create bool) (database string, adopted bool, err error) {
+	reg, err := OpenRegistry()
+	if err != nil {
+		return "", false, err
+	}
+	defer reg.Close()
+
+	database, registered, err := projectDatabase(reg, alias)
+	if err != nil {
+		return "", false, err
+	}
+	if registered {
+		return database, false, nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(database), 0o755)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create projects directory: %w", err)
+	}
+
+	legacy := alias + ".db"
+	if _, err := os.Stat(database); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil {
+			err = os.Rename(legacy, database)
+			if err != nil {
+				return "", false, fmt.Errorf("failed to move %s to %s: %w", legacy, database, err)
+			}
+			log.Printf("Moved database %s to %s", legacy, database)
+			return database, true, nil
+		}
+		if !create {
+			return "", false, fmt.Errorf("%w: '%s'", ErrProjectNotFound, alias)
+		}
+	}
+	return database, false, nil
+}
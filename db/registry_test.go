@@ -0,0 +1,99 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupDatabaseUsesDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(DataDirEnv, dataDir)
+
+	_, err := SetupDatabase("missing", 0)
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+
+	dbConn, err := SetupDatabase("proj", 4)
+	require.NoError(t, err)
+	require.NoError(t, dbConn.Close())
+	assert.FileExists(t, filepath.Join(dataDir, "projects", "proj.db"))
+
+	require.NoError(t, RegisterProject("proj", "/src/proj"))
+	entry, err := LookupProject("proj")
+	require.NoError(t, err)
+	assert.Equal(t, "/src/proj", entry.Path)
+	assert.Equal(t, filepath.Join(dataDir, "projects", "proj.db"), entry.Database)
+
+	// Any working directory resolves the same database.
+	t.Chdir(t.TempDir())
+	dbConn, err = SetupDatabase("proj", 0)
+	require.NoError(t, err)
+	require.NoError(t, dbConn.Close())
+
+	_, err = SetupDatabase("../proj", 4)
+	assert.ErrorContains(t, err, "invalid project alias")
+}
+
+func TestSetupDatabaseMovesLegacyDatabase(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(DataDirEnv, dataDir)
+	t.Chdir(t.TempDir())
+
+	legacy, err := InitDB("old", 4)
+	require.NoError(t, err)
+	require.NoError(t, UpsertProject(legacy, models.Project{Alias: "old", Path: "/src/old", Client: "ollama", Model: "m"}))
+	require.NoError(t, legacy.Close())
+
+	dbConn, err := SetupDatabase("old", 0)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	project, err := GetProjectByAlias(dbConn, "old")
+	require.NoError(t, err)
+	assert.Equal(t, "/src/old", project.Path)
+
+	_, err = os.Stat("old.db")
+	assert.True(t, os.IsNotExist(err))
+
+	entry, err := LookupProject("old")
+	require.NoError(t, err)
+	assert.Equal(t, "/src/old", entry.Path)
+}
+
+func TestCreateDatabaseRegistersProject(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(DataDirEnv, dataDir)
+	database := filepath.Join(dataDir, "projects", "proj.db")
+
+	dbConn, err := CreateDatabase("proj", "/src/proj", 4)
+	require.NoError(t, err)
+	require.NoError(t, dbConn.Close())
+	entry, err := LookupProject("proj")
+	require.NoError(t, err)
+	assert.Equal(t, database, entry.Database)
+
+	// A database that cannot be initialized is not left behind.
+	_, err = CreateDatabase("broken", "/src/broken", -1)
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dataDir, "projects", "broken.db"))
+
+	// Neither is one that cannot be registered, while existing databases
+	// are kept.
+	reg, err := OpenRegistry()
+	require.NoError(t, err)
+	_, err = reg.Exec("CREATE TRIGGER reject BEFORE INSERT ON projects BEGIN SELECT RAISE(ABORT, 'registry is read-only'); END")
+	require.NoError(t, err)
+	require.NoError(t, reg.Close())
+
+	_, err = CreateDatabase("unregistered", "/src/unregistered", 4)
+	assert.ErrorContains(t, err, "registry is read-only")
+	assert.NoFileExists(t, filepath.Join(dataDir, "projects", "unregistered.db"))
+
+	_, err = CreateDatabase("proj", "/src/proj", 4)
+	assert.ErrorContains(t, err, "registry is read-only")
+	assert.FileExists(t, database)
+}
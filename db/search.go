@@ -8,11 +8,57 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// MaxVectorResults is the largest number of nearest neighbours sqlite-vec
+// returns for one query.
+const MaxVectorResults = 4096
+
 // SearchResult represents a search result with distance information
 type SearchResult struct {
-	ID       int
-	File     string
-	Distance float64
+	ID        int     `json:"file_id"`
+	File      string  `json:"file"`
+	Distance  float64 `json:"score"`
+	ChunkID   int64   `json:"chunk_id,omitempty"`
+	Symbol    string  `json:"symbol,omitempty"`
+	StartLine int     `json:"start_line,omitempty"`
+	EndLine   int     `json:"end_line,omitempty"`
+	Hits      int     `json:"hits,omitempty"` // number of chunks aggregated into a file level result
+	// VectorDistance is the cosine distance of the chunk to the query
+	// embedding, nil when the chunk was not ranked by vector search.
+	VectorDistance *float64 `json:"distance,omitempty"`
+	// Project is the alias of the project of a cross-project search result.
+	Project string `json:"project,omitempty"`
+}
+
+// Location returns the result as "path:line", or just the path when the
+// result covers a whole file.
+func (r SearchResult) Location() string {
+	if r.StartLine > 1 {
+		return fmt.Sprintf("%s:%d", r.File, r.StartLine)
+	}
+	return r.File
+}
+
+// AggregateByFile merges chunk results into one result per file, keeping the
+// order and score of the best chunk of each file. Results must be sorted best
+// first. The aggregated results cover the whole file.
+func AggregateByFile(results []SearchResult) []SearchResult {
+	index := make(map[int]int)
+	var files []SearchResult
+	for _, result := range results {
+		if i, ok := index[result.ID]; ok {
+			files[i].Hits++
+			continue
+		}
+		index[result.ID] = len(files)
+		files = append(files, SearchResult{
+			ID:             result.ID,
+			File:           result.File,
+			Distance:       result.Distance,
+			Hits:           1,
+			VectorDistance: result.VectorDistance,
+		})
+	}
+	return files
 }
 
 // SearchOptions provides flexible search configuration
@@ -21,12 +67,15 @@ type SearchOptions struct {
 	MinResults  int     // Minimum number of results to return
 	MaxResults  int     // Maximum number of results to return
 	UseAdaptive bool    // Use adaptive threshold based on result distribution
+	// Keep, when set, drops results before the thresholds are applied, so
+	// that the adaptive threshold only considers results that are kept.
+	Keep func(SearchResult) bool
 }
 
 // DefaultSearchOptions returns sensible defaults
 func DefaultSearchOptions() SearchOptions {
 	return SearchOptions{
-		MaxDistance: 0.8, // Cosine similarity threshold
+		MaxDistance: 0.8, // Cosine distance threshold
 		MinResults:  2,
 		MaxResults:  20,
 		UseAdaptive: true,
@@ -45,11 +94,15 @@ func SearchWithThreshold(db *sql.DB, embeddings []float32, opts SearchOptions) (
 	if initialLimit < 100 {
 		initialLimit = 100
 	}
+	if initialLimit > MaxVectorResults {
+		initialLimit = MaxVectorResults
+	}
 
 	query := `
-        SELECT uf.id, uf.file, distance
-        FROM files uf
-        JOIN context_vectors cv ON cv.rowid = uf.id
+        SELECT uf.id, uf.file, c.id, c.symbol, c.start_line, c.end_line, distance
+        FROM chunks c
+        JOIN files uf ON uf.id = c.file_id
+        JOIN context_vectors cv ON cv.rowid = c.id
         WHERE cv.embedding MATCH vec_f32(?)
         AND k = ?
         ORDER BY distance ASC
@@ -64,10 +117,13 @@ func SearchWithThreshold(db *sql.DB, embeddings []float32, opts SearchOptions) (
 	var allResults []SearchResult
 	for rows.Next() {
 		var result SearchResult
-		err = rows.Scan(&result.ID, &result.File, &result.Distance)
+		err = rows.Scan(&result.ID, &result.File, &result.ChunkID, &result.Symbol, &result.StartLine, &result.EndLine, &result.Distance)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan embedding search row: %w", err)
 		}
+		if opts.Keep != nil && !opts.Keep(result) {
+			continue
+		}
 		allResults = append(allResults, result)
 	}
 
@@ -175,7 +231,13 @@ func SearchWithSimilarity(db *sql.DB, embeddings []float32, minSimilarity float6
 		MaxResults:  maxResults,
 		UseAdaptive: true,
 	}
+	return SearchSimilar(db, embeddings, opts)
+}
 
+// SearchSimilar runs SearchWithThreshold and converts the cosine distances of
+// the results to cosine similarity scores, 1 minus the distance. The distance
+// is kept as VectorDistance.
+func SearchSimilar(db *sql.DB, embeddings []float32, opts SearchOptions) ([]SearchResult, error) {
 	results, err := SearchWithThreshold(db, embeddings, opts)
 	if err != nil {
 		return nil, err
@@ -183,7 +245,9 @@ func SearchWithSimilarity(db *sql.DB, embeddings []float32, minSimilarity float6
 
 	// Convert distances to similarity scores
 	for i := range results {
-		results[i].Distance = 1.0 - results[i].Distance
+		distance := results[i].Distance
+		results[i].VectorDistance = &distance
+		results[i].Distance = 1.0 - distance
 	}
 
 	return results, nil
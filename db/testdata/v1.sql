@@ -0,0 +1,21 @@
+-- Schema and data as written by the first release, before schema versioning:
+-- one vector per file, keyed by file id.
+CREATE TABLE files (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	file TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE projects (
+	alias TEXT PRIMARY KEY NOT NULL,
+	path TEXT NOT NULL,
+	client TEXT NOT NULL,
+	model TEXT NOT NULL,
+	extensions TEXT NOT NULL DEFAULT ''
+);
+CREATE VIRTUAL TABLE context_vectors USING vec0(
+	embedding float[4],
+);
+
+INSERT INTO projects (alias, path, client, model, extensions) VALUES ('legacy', '/src/legacy', 'ollama', 'nomic-embed-text', 'go,md');
+INSERT INTO files (id, file) VALUES (1, '/main.go'), (2, '/README.md');
+INSERT INTO context_vectors (rowid, embedding) VALUES (1, '[1, 0, 0, 0]'), (2, '[0, 1, 0, 0]');
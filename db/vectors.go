@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// GetFileByPath returns the indexed file stored as path. It returns
+// sql.ErrNoRows when the file is not indexed.
+func GetFileByPath(db *sql.DB, path string) (models.File, error) {
+	var file models.File
+	var modTime int64
+	err := db.QueryRow("SELECT id, file, hash, size, mod_time, created_at FROM files WHERE file = ?", path).
+		Scan(&file.ID, &file.File, &file.Hash, &file.Size, &modTime, &file.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.File{}, err
+		}
+		return models.File{}, fmt.Errorf("failed to get file %s: %w", path, err)
+	}
+	if modTime != 0 {
+		file.ModTime = time.Unix(0, modTime)
+	}
+	return file, nil
+}
+
+// GetChunkVectors returns the stored embeddings of the chunks of fileID by
+// chunk id.
+func GetChunkVectors(db *sql.DB, fileID int64) (map[int64][]float32, error) {
+	rows, err := db.Query(`
+		SELECT c.id, cv.embedding
+		FROM chunks c
+		JOIN context_vectors cv ON cv.rowid = c.id
+		WHERE c.file_id = ?
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vectors for fileID %d: %w", fileID, err)
+	}
+	defer rows.Close()
+
+	vectors := make(map[int64][]float32)
+	for rows.Next() {
+		var chunkID int64
+		var blob []byte
+		if err := rows.Scan(&chunkID, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan vector row: %w", err)
+		}
+		vector, err := deserializeFloat32(blob)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", chunkID, err)
+		}
+		vectors[chunkID] = vector
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during vector row iteration: %w", err)
+	}
+	return vectors, nil
+}
+
+// deserializeFloat32 decodes a vector stored by sqlite-vec, little-endian
+// float32 values.
+func deserializeFloat32(blob []byte) ([]float32, error) {
+	if len(blob)%4 != 0 {
+		return nil, fmt.Errorf("invalid vector of %d bytes", len(blob))
+	}
+	vector := make([]float32, len(blob)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[i*4:]))
+	}
+	return vector, nil
+}
+
+// GetChunkIDs returns the ids of all chunks with a vector, in order.
+func GetChunkIDs(db *sql.DB) ([]int64, error) {
+	rows, err := db.Query("SELECT c.id FROM chunks c JOIN context_vectors cv ON cv.rowid = c.id ORDER BY c.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunk ids: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during chunk id iteration: %w", err)
+	}
+	return ids, nil
+}
+
+// GetChunkVector returns the stored embedding of a chunk.
+func GetChunkVector(db *sql.DB, chunkID int64) ([]float32, error) {
+	var blob []byte
+	err := db.QueryRow("SELECT embedding FROM context_vectors WHERE rowid = ?", chunkID).Scan(&blob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vector of chunk %d: %w", chunkID, err)
+	}
+	return deserializeFloat32(blob)
+}
@@ -1,18 +1,30 @@
 package file
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 )
 
-func RecursiveFiles(path string, extensions []string) ([]string, error) {
+// Options control which files RecursiveFiles returns.
+type Options struct {
+	// Extensions limits results to these file extensions, all files if empty.
+	Extensions []string
+	// Exclude are gitignore-style patterns relative to the walk root. They
+	// apply in addition to .gitignore and .codesearchignore files and cannot
+	// be negated by them.
+	Exclude []string
+}
+
+func RecursiveFiles(path string, opts Options) ([]string, error) {
 	var files []string
 
 	// Normalize extensions to include the dot and be lowercase
-	normalizedExts := make([]string, len(extensions))
-	for i, ext := range extensions {
+	normalizedExts := make([]string, len(opts.Extensions))
+	for i, ext := range opts.Extensions {
 		ext = strings.TrimSpace(ext)
 		if ext != "" && !strings.HasPrefix(ext, ".") {
 			ext = "." + ext
@@ -20,20 +32,40 @@ func RecursiveFiles(path string, extensions []string) ([]string, error) {
 		normalizedExts[i] = strings.ToLower(ext)
 	}
 
-	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+	ignorer := &Ignorer{}
+	excluder := NewIgnorer(opts.Exclude)
+
+	err := filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
 		if err != nil {
 			// Log the error but continue walking
 			log.Printf("Error accessing path %s: %v", filePath, err)
 			return nil
 		}
 
-		// Skip directories
-		if info.IsDir() {
+		relPath, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if d.IsDir() {
+			if relPath == "." {
+				relPath = ""
+			} else if d.Name() == ".git" || ignorer.Ignored(relPath, true) || excluder.Ignored(relPath, true) {
+				return filepath.SkipDir
+			}
+			if err := ignorer.LoadDir(filePath, relPath); err != nil {
+				log.Printf("Error reading ignore files in %s: %v", filePath, err)
+			}
+			return nil
+		}
+
+		// Skip hidden files (optional - remove if you want hidden files)
+		if strings.HasPrefix(d.Name(), ".") {
 			return nil
 		}
 
-		// Skip hidden files and directories (optional - remove if you want hidden files)
-		if strings.HasPrefix(info.Name(), ".") {
+		if ignorer.Ignored(relPath, false) || excluder.Ignored(relPath, false) {
 			return nil
 		}
 
@@ -55,3 +87,9 @@ func RecursiveFiles(path string, extensions []string) ([]string, error) {
 
 	return files, err
 }
+
+// Hash returns the hex encoded SHA-256 digest of content.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,114 @@
+package file
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reasons reported by Filter for skipped files.
+const (
+	SkipTooLarge  = "too large"
+	SkipBinary    = "binary"
+	SkipMinified  = "minified"
+	SkipGenerated = "generated"
+)
+
+// DefaultMaxSize is the default size limit in bytes for indexed files.
+const DefaultMaxSize = 1 << 20
+
+const (
+	// binarySniffLen is how much of a file is searched for NUL bytes.
+	binarySniffLen = 8000
+	// minifiedMinSize is the size below which files are never considered minified.
+	minifiedMinSize = 1024
+	// minifiedLineLength is the average line length above which a file is
+	// considered minified.
+	minifiedLineLength = 200
+	// generatedHeaderLines is how many leading lines are searched for a
+	// generated file marker.
+	generatedHeaderLines = 30
+)
+
+// generatedRe is the marker of generated files, see https://go.dev/s/generatedcode.
+var generatedRe = regexp.MustCompile(`^(?://|#|--|/\*|\*)\s*Code generated .* DO NOT EDIT\.`)
+
+// Filter decides which files are worth indexing based on their content.
+type Filter struct {
+	// MaxSize skips files larger than this many bytes, zero for no limit.
+	MaxSize int64
+	// IncludeGenerated keeps files marked "Code generated ... DO NOT EDIT."
+	IncludeGenerated bool
+	// IncludeMinified keeps minified files.
+	IncludeMinified bool
+}
+
+// DefaultFilter returns the filter used for new projects.
+func DefaultFilter() Filter {
+	return Filter{MaxSize: DefaultMaxSize}
+}
+
+// CheckSize returns the reason to skip a file of size bytes, or "".
+func (f Filter) CheckSize(size int64) string {
+	if f.MaxSize > 0 && size > f.MaxSize {
+		return SkipTooLarge
+	}
+	return ""
+}
+
+// Check returns the reason to skip a file with content, or "".
+// Binary files are always skipped.
+func (f Filter) Check(path string, content []byte) string {
+	if reason := f.CheckSize(int64(len(content))); reason != "" {
+		return reason
+	}
+	if IsBinary(content) {
+		return SkipBinary
+	}
+	if !f.IncludeMinified && IsMinified(path, content) {
+		return SkipMinified
+	}
+	if !f.IncludeGenerated && IsGenerated(content) {
+		return SkipGenerated
+	}
+	return ""
+}
+
+// IsBinary reports whether content looks binary: it contains NUL bytes near
+// the start or is not valid UTF-8.
+func IsBinary(content []byte) bool {
+	sniff := content
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	return bytes.IndexByte(sniff, 0) >= 0 || !utf8.Valid(content)
+}
+
+// IsMinified reports whether a file is minified, either by its ".min." name
+// or by a very long average line length.
+func IsMinified(path string, content []byte) bool {
+	if strings.Contains(strings.ToLower(filepath.Base(path)), ".min.") {
+		return true
+	}
+	if len(content) < minifiedMinSize {
+		return false
+	}
+	lines := bytes.Count(content, []byte("\n")) + 1
+	return len(content)/lines > minifiedLineLength
+}
+
+// IsGenerated reports whether the header of content carries the
+// "Code generated ... DO NOT EDIT." marker.
+func IsGenerated(content []byte) bool {
+	for i, line := range strings.SplitN(string(content), "\n", generatedHeaderLines+1) {
+		if i == generatedHeaderLines {
+			break
+		}
+		if generatedRe.MatchString(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
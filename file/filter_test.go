@@ -0,0 +1,39 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterCheck(t *testing.T) {
+	longLine := strings.Repeat("var a=1;", 300)
+
+	testCases := []struct {
+		name    string
+		filter  Filter
+		path    string
+		content string
+		reason  string
+	}{
+		{"plain source", DefaultFilter(), "main.go", "package main\n\nfunc main() {}\n", ""},
+		{"too large", Filter{MaxSize: 10}, "main.go", "package main\n", SkipTooLarge},
+		{"no size limit", Filter{}, "main.go", strings.Repeat("x\n", 1000), ""},
+		{"nul byte", DefaultFilter(), "data.js", "abc\x00def", SkipBinary},
+		{"invalid utf8", DefaultFilter(), "data.js", "abc\xff\xfe", SkipBinary},
+		{"min file name", DefaultFilter(), "static/app.min.js", "var a=1;", SkipMinified},
+		{"long lines", DefaultFilter(), "bundle.js", longLine, SkipMinified},
+		{"include minified", Filter{IncludeMinified: true}, "bundle.js", longLine, ""},
+		{"go generated", DefaultFilter(), "api.pb.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n", SkipGenerated},
+		{"python generated", DefaultFilter(), "schema.py", "#!/usr/bin/env python\n# Code generated by tool. DO NOT EDIT.\n", SkipGenerated},
+		{"include generated", Filter{IncludeGenerated: true}, "api.pb.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\npackage api\n", ""},
+		{"marker in body is ignored", DefaultFilter(), "gen.go", "package gen\n\nconst header = \"Code generated by x. DO NOT EDIT.\"\n", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.reason, tc.filter.Check(tc.path, []byte(tc.content)))
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package file
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFiles are the per-directory files read for gitignore-style patterns.
+var IgnoreFiles = []string{".gitignore", ".codesearchignore"}
+
+// ignoreRule is a single compiled gitignore pattern.
+type ignoreRule struct {
+	base    string // slash separated directory the pattern is relative to, "" for root
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// parseIgnoreRule compiles a gitignore line. It returns false for blank lines
+// and comments.
+func parseIgnoreRule(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern without a slash (other than a trailing one) matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if !anchored {
+		line = "**/" + line
+	}
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp translates gitignore glob syntax to a regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match reports whether the rule applies to relPath, a slash separated path
+// relative to the walk root.
+func (r ignoreRule) match(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(relPath, r.base+"/") {
+			return false
+		}
+		relPath = relPath[len(r.base)+1:]
+	}
+	return r.re.MatchString(relPath)
+}
+
+// Ignorer decides which paths are excluded by gitignore-style rules.
+// Rules from deeper directories and later lines take precedence, and a
+// negated rule re-includes a path excluded by an earlier one.
+type Ignorer struct {
+	rules []ignoreRule
+}
+
+// NewIgnorer creates an Ignorer with patterns relative to the walk root.
+func NewIgnorer(patterns []string) *Ignorer {
+	ig := &Ignorer{}
+	for _, p := range patterns {
+		ig.add(p, "")
+	}
+	return ig
+}
+
+func (ig *Ignorer) add(line, base string) {
+	if rule, ok := parseIgnoreRule(line, base); ok {
+		ig.rules = append(ig.rules, rule)
+	}
+}
+
+// LoadDir reads the ignore files of dir, where relDir is dir relative to the
+// walk root ("" for the root itself). Missing files are skipped.
+func (ig *Ignorer) LoadDir(dir, relDir string) error {
+	for _, name := range IgnoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			ig.add(scanner.Text(), relDir)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ignored reports whether relPath, relative to the walk root, is excluded.
+func (ig *Ignorer) Ignored(relPath string, isDir bool) bool {
+	relPath = path.Clean(filepath.ToSlash(relPath))
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.match(relPath, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// IgnoredPath reports whether the file at relPath is excluded, either by a
+// rule matching the file or by one matching any of its parent directories, as
+// it would be during a walk.
+func (ig *Ignorer) IgnoredPath(relPath string) bool {
+	relPath = strings.TrimPrefix(path.Clean(filepath.ToSlash(relPath)), "/")
+	for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if ig.Ignored(dir, true) {
+			return true
+		}
+	}
+	return ig.Ignored(relPath, false)
+}
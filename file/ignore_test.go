@@ -0,0 +1,92 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnorer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		ignored  bool
+	}{
+		{"basename at any depth", []string{"node_modules"}, "web/node_modules", true, true},
+		{"basename file", []string{"*.min.js"}, "static/js/app.min.js", false, true},
+		{"no match", []string{"*.min.js"}, "static/js/app.js", false, false},
+		{"anchored pattern", []string{"/build"}, "build", true, true},
+		{"anchored pattern not nested", []string{"/build"}, "src/build", true, false},
+		{"pattern with slash is anchored", []string{"docs/generated"}, "docs/generated", true, true},
+		{"pattern with slash not nested", []string{"docs/generated"}, "x/docs/generated", true, false},
+		{"directory only skips files", []string{"dist/"}, "dist", false, false},
+		{"directory only matches dirs", []string{"dist/"}, "pkg/dist", true, true},
+		{"double star in middle", []string{"a/**/b.go"}, "a/x/y/b.go", false, true},
+		{"double star directly", []string{"a/**/b.go"}, "a/b.go", false, true},
+		{"trailing double star", []string{"vendor/**"}, "vendor/x/y.go", false, true},
+		{"negation re-includes", []string{"*.go", "!keep.go"}, "pkg/keep.go", false, false},
+		{"later rule wins", []string{"!keep.go", "*.go"}, "pkg/keep.go", false, true},
+		{"question mark", []string{"file?.txt"}, "file1.txt", false, true},
+		{"character class", []string{"file[0-9].txt"}, "filea.txt", false, false},
+		{"comment", []string{"# *.go"}, "main.go", false, false},
+		{"escaped hash", []string{`\#notes`}, "#notes", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ig := NewIgnorer(tc.patterns)
+			assert.Equal(t, tc.ignored, ig.Ignored(tc.path, tc.isDir))
+		})
+	}
+}
+
+func TestRecursiveFilesIgnore(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	write(".gitignore", "node_modules/\n*.gen.go\n")
+	write(".codesearchignore", "testdata/\n")
+	write("main.go", "package main")
+	write("api.gen.go", "package main")
+	write("node_modules/lib/index.js", "x")
+	write("testdata/fixture.go", "package testdata")
+	write("internal/.gitignore", "secret.go\n!keep.gen.go\n")
+	write("internal/secret.go", "package internal")
+	write("internal/keep.gen.go", "package internal")
+	write("internal/public.go", "package internal")
+	write("web/app.js", "x")
+	write("web/dist/bundle.js", "x")
+	write(".git/config.go", "x")
+
+	files, err := RecursiveFiles(root, Options{Extensions: []string{"go", "js"}, Exclude: []string{"web/dist"}})
+	require.NoError(t, err)
+
+	var rel []string
+	for _, f := range files {
+		r, err := filepath.Rel(root, f)
+		require.NoError(t, err)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+
+	assert.ElementsMatch(t, []string{"main.go", "internal/keep.gen.go", "internal/public.go", "web/app.js"}, rel)
+}
+
+func TestIgnoredPath(t *testing.T) {
+	ig := NewIgnorer([]string{"vendor", "internal/**", "*_test.go"})
+
+	assert.True(t, ig.IgnoredPath("/vendor/lib/a.go"), "parent directory matches at any depth")
+	assert.True(t, ig.IgnoredPath("pkg/vendor/a.go"))
+	assert.True(t, ig.IgnoredPath("/internal/auth/login.go"))
+	assert.True(t, ig.IgnoredPath("cmd/root_test.go"))
+	assert.False(t, ig.IgnoredPath("/cmd/root.go"))
+	assert.False(t, ig.IgnoredPath("pkg/internal/a.go"), "slashed patterns are anchored at the root")
+}
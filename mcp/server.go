@@ -0,0 +1,184 @@
+// Package mcp implements a Model Context Protocol server over stdio, so that
+// coding agents can use project indexes as tools.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// ProtocolVersion is the MCP revision implemented by the server.
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// isNotification reports whether the request expects no response.
+func (r request) isNotification() bool {
+	return len(r.ID) == 0
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Tool is a function exposed to MCP clients.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	// Handler returns the text result of a call. Errors are reported to the
+	// client as tool errors rather than protocol errors.
+	Handler func(ctx context.Context, args json.RawMessage) (string, error) `json:"-"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+// Server answers MCP requests with a fixed set of tools.
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+
+	mu  sync.Mutex // serializes writes to out
+	out *json.Encoder
+}
+
+// NewServer returns a server exposing tools.
+func NewServer(name, version string, tools []Tool) *Server {
+	return &Server{name: name, version: version, tools: tools}
+}
+
+// Serve reads newline-delimited JSON-RPC messages from in and writes the
+// responses to out until in is exhausted or ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.out = json.NewEncoder(out)
+	dec := json.NewDecoder(in)
+
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			// The stream cannot be resynchronised after malformed JSON.
+			s.write(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			return fmt.Errorf("error reading request: %w", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var req request
+		if err := json.Unmarshal(raw, &req); err != nil || req.Method == "" {
+			s.write(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeInvalidRequest, Message: "invalid request"}})
+			continue
+		}
+		s.handle(ctx, req)
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req request) {
+	result, rpcErr := s.dispatch(ctx, req)
+	if req.isNotification() {
+		return
+	}
+
+	resp := response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+	if rpcErr == nil && result == nil {
+		resp.Result = struct{}{}
+	}
+	s.write(resp)
+}
+
+func (s *Server) dispatch(ctx context.Context, req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": s.name, "version": s.version},
+		}, nil
+	case "ping":
+		return nil, nil
+	case "tools/list":
+		return map[string]any{"tools": s.tools}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	}
+
+	if req.isNotification() {
+		// Notifications such as notifications/initialized need no handling.
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (any, *rpcError) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	for _, tool := range s.tools {
+		if tool.Name != params.Name {
+			continue
+		}
+		args := params.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		text, err := tool.Handler(ctx, args)
+		if err != nil {
+			log.Printf("Tool %s failed: %v", tool.Name, err)
+			return toolResult{Content: []textContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		return toolResult{Content: []textContent{{Type: "text", Text: text}}}, nil
+	}
+	return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", params.Name)}
+}
+
+func (s *Server) write(resp response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.out.Encode(resp); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
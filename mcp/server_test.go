@@ -0,0 +1,87 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve runs a server over the given request lines and returns the decoded responses.
+func serve(t *testing.T, tools []Tool, lines ...string) []map[string]any {
+	var out strings.Builder
+	err := NewServer("test", "1.0", tools).Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &out)
+	require.NoError(t, err)
+
+	var responses []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &resp))
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestServe(t *testing.T) {
+	echo := Tool{
+		Name:        "echo",
+		InputSchema: objectSchema(map[string]any{"text": stringProperty("")}, "text"),
+		Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return "", err
+			}
+			if args.Text == "" {
+				return "", errors.New("text is required")
+			}
+			return args.Text, nil
+		},
+	}
+
+	responses := serve(t, []Tool{echo},
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":"two","method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":7,"method":"ping"}`,
+	)
+	require.Len(t, responses, 7)
+
+	init := responses[0]["result"].(map[string]any)
+	assert.Equal(t, ProtocolVersion, init["protocolVersion"])
+	assert.Equal(t, "test", init["serverInfo"].(map[string]any)["name"])
+
+	assert.Equal(t, "two", responses[1]["id"])
+	tools := responses[1]["result"].(map[string]any)["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "echo", tools[0].(map[string]any)["name"])
+	assert.Equal(t, "object", tools[0].(map[string]any)["inputSchema"].(map[string]any)["type"])
+
+	content := responses[2]["result"].(map[string]any)["content"].([]any)
+	assert.Equal(t, "hi", content[0].(map[string]any)["text"])
+
+	failed := responses[3]["result"].(map[string]any)
+	assert.Equal(t, true, failed["isError"])
+
+	assert.Equal(t, float64(codeInvalidParams), responses[4]["error"].(map[string]any)["code"])
+	assert.Equal(t, float64(codeMethodNotFound), responses[5]["error"].(map[string]any)["code"])
+	assert.Equal(t, map[string]any{}, responses[6]["result"])
+}
+
+func TestServeMalformed(t *testing.T) {
+	var out strings.Builder
+	err := NewServer("test", "1.0", nil).Serve(context.Background(), strings.NewReader(`{"jsonrpc":`), &out)
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), `"code":-32700`)
+}
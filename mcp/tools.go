@@ -0,0 +1,218 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/project"
+	"github.com/andrejsstepanovs/codesearch/search"
+	codesync "github.com/andrejsstepanovs/codesearch/sync"
+)
+
+// maxSnippetLines limits how much of a file get_file_snippet returns at once.
+const maxSnippetLines = 400
+
+// Tools returns the codesearch tools. clientOptions configure how embedding
+// providers are reached, as the global command line flags do.
+func Tools(clientOptions client.Options) []Tool {
+	return []Tool{
+		{
+			Name:        "search_code",
+			Description: "Search an indexed project for code matching a natural language or keyword query. Returns file locations with line numbers, symbols and scores, best first.",
+			InputSchema: objectSchema(map[string]any{
+				"project": stringProperty("Project alias, see list_projects"),
+				"query":   stringProperty("What to look for, e.g. \"where are JWT tokens validated\" or an identifier"),
+				"limit":   integerProperty("Maximum number of results (default: the project default or 10)"),
+				"mode":    map[string]any{"type": "string", "enum": search.Modes, "description": "Ranking mode (default vector)"},
+			}, "project", "query"),
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					Project string `json:"project"`
+					Query   string `json:"query"`
+					Limit   int    `json:"limit"`
+					Mode    string `json:"mode"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				return searchCode(ctx, clientOptions, args.Project, args.Query, args.Limit, args.Mode)
+			},
+		},
+		{
+			Name:        "list_projects",
+			Description: "List indexed projects with their source path, embedding model, file count and last sync time.",
+			InputSchema: objectSchema(map[string]any{}),
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				return listProjects()
+			},
+		},
+		{
+			Name:        "get_file_snippet",
+			Description: "Read lines of a file of an indexed project, e.g. around a search_code result.",
+			InputSchema: objectSchema(map[string]any{
+				"project":    stringProperty("Project alias"),
+				"path":       stringProperty("File path as returned by search_code, relative to the project root"),
+				"start_line": integerProperty("First line, 1-based (default 1)"),
+				"end_line":   integerProperty(fmt.Sprintf("Last line, inclusive (default start_line + %d)", maxSnippetLines-1)),
+			}, "project", "path"),
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					Project   string `json:"project"`
+					Path      string `json:"path"`
+					StartLine int    `json:"start_line"`
+					EndLine   int    `json:"end_line"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				return fileSnippet(args.Project, args.Path, args.StartLine, args.EndLine)
+			},
+		},
+		{
+			Name:        "sync_project",
+			Description: "Update the index of a project with changed, added and removed files, using its stored settings.",
+			InputSchema: objectSchema(map[string]any{
+				"project": stringProperty("Project alias"),
+			}, "project"),
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					Project string `json:"project"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				return syncProject(ctx, clientOptions, args.Project)
+			},
+		},
+	}
+}
+
+func searchCode(ctx context.Context, clientOptions client.Options, alias, query string, limit int, mode string) (string, error) {
+	config, err := search.ParseConfig([]string{alias, query})
+	if err != nil {
+		return "", err
+	}
+	config.ClientOptions = clientOptions
+	if limit > 0 {
+		config.Limit = limit
+	}
+	if mode != "" {
+		config.Mode = mode
+	}
+
+	results, err := search.Run(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "No results", nil
+	}
+
+	var b strings.Builder
+	for _, r := range results {
+		fmt.Fprintf(&b, "%s", r.Location())
+		if r.EndLine > r.StartLine {
+			fmt.Fprintf(&b, "-%d", r.EndLine)
+		}
+		if r.Symbol != "" {
+			fmt.Fprintf(&b, "\t%s", r.Symbol)
+		}
+		fmt.Fprintf(&b, "\t(score %.4f)\n", r.Distance)
+	}
+	return b.String(), nil
+}
+
+func listProjects() (string, error) {
+	summaries, err := project.List()
+	if err != nil {
+		return "", err
+	}
+	if len(summaries) == 0 {
+		return "No projects found", nil
+	}
+
+	var b strings.Builder
+	for _, s := range summaries {
+		if s.Err != nil {
+			fmt.Fprintf(&b, "%s\t%s\terror: %v\n", s.Alias, s.Path, s.Err)
+			continue
+		}
+		synced := "never"
+		if !s.SyncedAt.IsZero() {
+			synced = s.SyncedAt.UTC().Format("2006-01-02T15:04:05Z")
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s/%s\t%d files\tlast sync %s\n", s.Alias, s.Path, s.Client, s.Model, s.Stats.Files, synced)
+	}
+	return b.String(), nil
+}
+
+func fileSnippet(alias, path string, start, end int) (string, error) {
+	entry, err := db.LookupProject(alias)
+	if err != nil {
+		return "", err
+	}
+
+	if start < 1 {
+		start = 1
+	}
+	if end == 0 || end-start+1 > maxSnippetLines {
+		end = start + maxSnippetLines - 1
+	}
+
+	lines, err := project.ReadLines(entry.Path, path, start, end)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&b, "%d\t%s\n", start+i, line)
+	}
+	return b.String(), nil
+}
+
+func syncProject(ctx context.Context, clientOptions client.Options, alias string) (string, error) {
+	// The report goes to the tool result; standard output carries the protocol.
+	var out bytes.Buffer
+	config := &codesync.Config{
+		ProjectAlias:  alias,
+		ClientOptions: clientOptions,
+		BatchSize:     codesync.DefaultBatchSize,
+		BatchBytes:    codesync.DefaultBatchBytes,
+		Workers:       codesync.DefaultWorkers,
+		Output:        &out,
+		Progress:      io.Discard,
+	}
+
+	err := codesync.RunSync(ctx, config)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProperty(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func integerProperty(description string) map[string]any {
+	return map[string]any{"type": "integer", "description": description}
+}
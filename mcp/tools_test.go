@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callTool(t *testing.T, name string, args any) (string, error) {
+	raw, err := json.Marshal(args)
+	require.NoError(t, err)
+	for _, tool := range Tools(client.Options{}) {
+		if tool.Name == name {
+			return tool.Handler(context.Background(), raw)
+		}
+	}
+	t.Fatalf("tool %s not found", name)
+	return "", nil
+}
+
+func TestProjectTools(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644))
+
+	dbConn, err := db.SetupDatabase("demo", 2)
+	require.NoError(t, err)
+	require.NoError(t, db.UpsertProject(dbConn, models.Project{Alias: "demo", Path: projectDir, Client: "ollama", Model: "m"}))
+	require.NoError(t, dbConn.Close())
+	require.NoError(t, db.RegisterProject("demo", projectDir))
+
+	text, err := callTool(t, "list_projects", map[string]any{})
+	require.NoError(t, err)
+	assert.Contains(t, text, "demo\t"+projectDir+"\tollama/m\t0 files\tlast sync never")
+
+	text, err = callTool(t, "get_file_snippet", map[string]any{"project": "demo", "path": "/main.go", "start_line": 3, "end_line": 4})
+	require.NoError(t, err)
+	assert.Equal(t, "3\tfunc main() {\n4\t}\n", text)
+
+	_, err = callTool(t, "get_file_snippet", map[string]any{"project": "demo", "path": "../secret"})
+	assert.ErrorContains(t, err, "outside of the project")
+
+	_, err = callTool(t, "search_code", map[string]any{"project": "missing", "query": "main"})
+	assert.ErrorContains(t, err, "not found")
+}
@@ -1,5 +1,7 @@
 package models
 
+import "fmt"
+
 type EmbeddingUsage struct {
 	PromptTokens int `json:"prompt_tokens"`
 	TotalTokens  int `json:"total_tokens"`
@@ -31,6 +33,32 @@ func (er EmbeddingResponse) GetEmbeddings() *Embedding {
 	return nil
 }
 
+// Vectors returns the embeddings of a response for n inputs in input order.
+// Ollama returns them in order, OpenAI compatible APIs tag each with its index.
+func (er EmbeddingResponse) Vectors(n int) ([]Embedding, error) {
+	if len(er.Embeddings) > 0 {
+		if len(er.Embeddings) != n {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", n, len(er.Embeddings))
+		}
+		return er.Embeddings, nil
+	}
+
+	if len(er.Data) != n {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", n, len(er.Data))
+	}
+	vectors := make([]Embedding, n)
+	for _, d := range er.Data {
+		if d.Index < 0 || d.Index >= n {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		if vectors[d.Index] != nil {
+			return nil, fmt.Errorf("duplicate embedding index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
 func (e *Embedding) Float32() []float32 {
 	float32s := make([]float32, len(*e))
 	for i, v := range *e {
@@ -40,6 +68,6 @@ func (e *Embedding) Float32() []float32 {
 }
 
 type EmbeddingRequest struct {
-	Model string `json:"model"`
-	Input string `json:"input"`
+	Model string   `json:"model"`
+	Input []string `json:"input"`
 }
@@ -9,11 +9,106 @@ type Project struct {
 	Client     string
 	Model      string
 	Extensions []string
+	// Exclude are gitignore-style patterns of files to skip.
+	Exclude []string
+	// MaxFileSize skips larger files, zero for no limit.
+	MaxFileSize int64
+	// IncludeGenerated and IncludeMinified keep files that are skipped by default.
+	IncludeGenerated bool
+	IncludeMinified  bool
+	// ChunkLines, ChunkOverlap and ChunkTokens configure the line-window
+	// chunker for non-Go files. ChunkLines of zero embeds whole files.
+	ChunkLines   int
+	ChunkOverlap int
+	ChunkTokens  int
+	// MaxTokens is the approximate model input limit per chunk, zero uses
+	// the known limit of the model.
+	MaxTokens int
+	// Dimensions and Fingerprint identify the vectors the model produced
+	// when the project was built, see client.Identity.
+	Dimensions  int
+	Fingerprint string
+	// SyncedAt is when the last build or sync finished, zero if never.
+	SyncedAt time.Time
+	// Search holds the defaults of find for this project. They survive
+	// rebuilds.
+	Search SearchDefaults
+	// Templates wrap the text embedded for chunks and queries.
+	Templates Templates
+	// KeywordIndex is true when the keyword index holds every chunk. It is
+	// not for projects indexed before keyword search or changed by a build
+	// without it; sync then rebuilds the keyword index.
+	KeywordIndex bool
+}
+
+// Templates format the text sent to the embedding model, for models that
+// expect instruction prefixes such as "search_document: " and
+// "search_query: ". Empty templates keep the default input.
+type Templates struct {
+	// Document is applied to each chunk and may use the placeholders
+	// {path}, {language}, {symbol} and {content}.
+	Document string
+	// Query is applied to search queries and may use {query}.
+	Query string
+}
+
+// SearchDefaults tune how results are selected. A zero Limit and nil fields
+// fall back to the next level of defaults.
+type SearchDefaults struct {
+	Limit int `json:"limit,omitempty"`
+	// MinSimilarity drops vector results with a lower cosine similarity.
+	MinSimilarity *float64 `json:"min_similarity,omitempty"`
+	// MaxDistance drops vector results with a larger cosine distance. It is
+	// the counterpart of MinSimilarity, the stricter of both applies.
+	MaxDistance *float64 `json:"max_distance,omitempty"`
+	// MinResults are returned even when fewer pass the thresholds.
+	MinResults *int `json:"min_results,omitempty"`
+	// Adaptive cuts results at the largest gap in their distances.
+	Adaptive *bool `json:"adaptive,omitempty"`
+}
+
+// IsZero reports whether no default is set.
+func (d SearchDefaults) IsZero() bool {
+	return d == SearchDefaults{}
+}
+
+// Or returns d with unset fields taken from fallback.
+func (d SearchDefaults) Or(fallback SearchDefaults) SearchDefaults {
+	if d.Limit == 0 {
+		d.Limit = fallback.Limit
+	}
+	if d.MinSimilarity == nil {
+		d.MinSimilarity = fallback.MinSimilarity
+	}
+	if d.MaxDistance == nil {
+		d.MaxDistance = fallback.MaxDistance
+	}
+	if d.MinResults == nil {
+		d.MinResults = fallback.MinResults
+	}
+	if d.Adaptive == nil {
+		d.Adaptive = fallback.Adaptive
+	}
+	return d
 }
 
 // File represents a file record in the database.
 type File struct {
 	ID        int64
 	File      string
+	Hash      string
+	Size      int64
+	ModTime   time.Time
 	CreatedAt time.Time
 }
+
+// Chunk is a part of a file that is embedded as its own vector.
+// Line numbers are 1-based and inclusive; zero means the whole file.
+type Chunk struct {
+	ID        int64
+	FileID    int64
+	Symbol    string
+	StartLine int
+	EndLine   int
+	Content   string
+}
@@ -0,0 +1,57 @@
+package project
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// StoredIdentity returns the model identity a project was built with.
+// Projects built before identities were recorded only know the dimensions of
+// their vector table.
+func StoredIdentity(dbConn *sql.DB, p *models.Project) (client.Identity, error) {
+	stored := client.Identity{
+		Client:      p.Client,
+		Model:       p.Model,
+		Dimensions:  p.Dimensions,
+		Fingerprint: p.Fingerprint,
+	}
+	if stored.Dimensions == 0 {
+		dimensions, err := db.VectorDimensions(dbConn)
+		if err != nil {
+			return client.Identity{}, err
+		}
+		stored.Dimensions = dimensions
+	}
+	return stored, nil
+}
+
+// CheckModel verifies that current, the identity of the model now behind the
+// project client and model, matches the one the index was built with, and
+// explains how to rebuild otherwise. Projects without a stored fingerprint
+// get the current one recorded, in the database and in p.
+func CheckModel(dbConn *sql.DB, p *models.Project, current client.Identity) error {
+	stored, err := StoredIdentity(dbConn, p)
+	if err != nil {
+		return err
+	}
+
+	err = stored.Check(current)
+	if err != nil {
+		return fmt.Errorf("project '%s': %w. Rebuild the index with: codesearch build %s %s %s %s %s",
+			p.Alias, err, p.Alias, p.Path, p.Client, p.Model, strings.Join(p.Extensions, ","))
+	}
+
+	if p.Fingerprint == "" {
+		err = db.SetProjectIdentity(dbConn, p.Alias, current.Dimensions, current.Fingerprint)
+		if err != nil {
+			return err
+		}
+		p.Dimensions, p.Fingerprint = current.Dimensions, current.Fingerprint
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package project
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// Summary describes a registered project and its index.
+type Summary struct {
+	models.Project
+	Registry     db.RegistryEntry
+	DatabaseSize int64
+	Stats        db.Stats
+	// Err is set when the database of a listed project could not be read.
+	Err error
+}
+
+// List summarizes all registered projects. Projects whose database cannot be
+// read are still listed, with Err set.
+func List() ([]Summary, error) {
+	entries, err := db.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]Summary, 0, len(entries))
+	for _, entry := range entries {
+		summary, err := summarize(entry)
+		if err != nil {
+			summary = Summary{Project: models.Project{Alias: entry.Alias, Path: entry.Path}, Registry: entry, Err: err}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// Info summarizes a single registered project.
+func Info(alias string) (Summary, error) {
+	entry, err := db.LookupProject(alias)
+	if err != nil {
+		return Summary{}, err
+	}
+	return summarize(entry)
+}
+
+// Remove deletes the index of a project. The project sources are not touched.
+func Remove(alias string) error {
+	return db.RemoveProject(alias)
+}
+
+// SetSearchDefaults stores the set fields of defaults as the find defaults of
+// a project, keeping the other stored defaults unless reset is true. It
+// returns the stored defaults.
+func SetSearchDefaults(alias string, defaults models.SearchDefaults, reset bool) (models.SearchDefaults, error) {
+	dbConn, err := db.SetupDatabase(alias, 0)
+	if err != nil {
+		return models.SearchDefaults{}, err
+	}
+	defer dbConn.Close()
+
+	proj, err := db.GetProjectByAlias(dbConn, alias)
+	if err != nil {
+		return models.SearchDefaults{}, fmt.Errorf("failed to get project '%s': %w", alias, err)
+	}
+
+	if !reset {
+		defaults = defaults.Or(proj.Search)
+	}
+	if err := db.SetSearchDefaults(dbConn, alias, defaults); err != nil {
+		return models.SearchDefaults{}, err
+	}
+	return defaults, nil
+}
+
+func summarize(entry db.RegistryEntry) (Summary, error) {
+	info, err := os.Stat(entry.Database)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to read database of project '%s': %w", entry.Alias, err)
+	}
+
+	dbConn, err := db.SetupDatabase(entry.Alias, 0)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer dbConn.Close()
+
+	proj, err := db.GetProjectByAlias(dbConn, entry.Alias)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to get project '%s': %w", entry.Alias, err)
+	}
+
+	stats, err := db.GetStats(dbConn)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return Summary{
+		Project:      *proj,
+		Registry:     entry,
+		DatabaseSize: info.Size(),
+		Stats:        stats,
+	}, nil
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "1.5 MiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,205 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildProject(t *testing.T, alias string, files int) {
+	dbConn, err := db.SetupDatabase(alias, 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	require.NoError(t, db.UpsertProject(dbConn, models.Project{Alias: alias, Path: "/src/" + alias, Client: "ollama", Model: "m", Extensions: []string{"go"}}))
+	require.NoError(t, db.RegisterProject(alias, "/src/"+alias))
+	for i := range files {
+		chunks := []models.Chunk{{StartLine: 1, EndLine: 1}, {StartLine: 2, EndLine: 2}}
+		_, err := db.SaveFileChunks(dbConn, models.File{File: filepath.Join("/", alias, string(rune('a'+i))), Size: 10}, chunks, []models.Embedding{{1, 0}, {0, 1}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.MarkProjectSynced(dbConn, alias, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestListInfoRemove(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+
+	buildProject(t, "beta", 1)
+	buildProject(t, "alpha", 3)
+
+	summaries, err := List()
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "alpha", summaries[0].Alias)
+	assert.Equal(t, "beta", summaries[1].Alias)
+
+	info, err := Info("alpha")
+	require.NoError(t, err)
+	assert.Equal(t, "/src/alpha", info.Path)
+	assert.Equal(t, "ollama", info.Client)
+	assert.Equal(t, db.Stats{Files: 3, Chunks: 6, SourceBytes: 30}, info.Stats)
+	assert.Positive(t, info.DatabaseSize)
+	assert.True(t, info.SyncedAt.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+
+	require.NoError(t, Remove("alpha"))
+	assert.NoFileExists(t, info.Registry.Database)
+
+	_, err = Info("alpha")
+	assert.ErrorIs(t, err, db.ErrProjectNotFound)
+	assert.ErrorIs(t, Remove("alpha"), db.ErrProjectNotFound)
+
+	summaries, err = List()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "beta", summaries[0].Alias)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0 MiB", FormatBytes(2<<20))
+}
+
+func TestCheckModel(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+	buildProject(t, "legacy", 1)
+
+	dbConn, err := db.SetupDatabase("legacy", 0)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	p, err := db.GetProjectByAlias(dbConn, "legacy")
+	require.NoError(t, err)
+
+	// Without a stored identity only the vector table size is checked.
+	err = CheckModel(dbConn, p, client.NewIdentity("ollama", "m", models.Embedding{1, 0, 0}))
+	assert.ErrorIs(t, err, client.ErrModelMismatch)
+	assert.ErrorContains(t, err, "codesearch build legacy /src/legacy ollama m go")
+
+	require.NoError(t, CheckModel(dbConn, p, client.NewIdentity("ollama", "m", models.Embedding{1, 0})))
+
+	// The first successful check records the fingerprint.
+	p, err = db.GetProjectByAlias(dbConn, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, 2, p.Dimensions)
+	assert.NotEmpty(t, p.Fingerprint)
+
+	err = CheckModel(dbConn, p, client.NewIdentity("ollama", "m", models.Embedding{0, 1}))
+	assert.ErrorIs(t, err, client.ErrModelMismatch)
+}
+
+func TestReadLines(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\ntwo\nthree\n"), 0644))
+
+	lines, err := ReadLines(root, "/a.txt", 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"two", "three"}, lines)
+
+	lines, err = ReadLines(root, "a.txt", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one"}, lines)
+
+	_, err = ReadLines(root, "/../a.txt", 1, 1)
+	assert.ErrorContains(t, err, "outside of the project")
+
+	_, err = ReadLines(root, "/a.txt", 3, 2)
+	assert.Error(t, err)
+
+	// Links are followed as long as they stay inside the project.
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("secret\n"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "secret.txt")))
+	require.NoError(t, os.Symlink(filepath.Dir(outside), filepath.Join(root, "elsewhere")))
+	require.NoError(t, os.Symlink(filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")))
+
+	_, err = ReadLines(root, "/secret.txt", 1, 1)
+	assert.ErrorContains(t, err, "outside of the project")
+	_, err = ReadLines(root, "/elsewhere/secret.txt", 1, 1)
+	assert.ErrorContains(t, err, "outside of the project")
+	lines, err = ReadLines(root, "/b.txt", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one"}, lines)
+}
+
+func TestQueryTerms(t *testing.T) {
+	assert.Equal(t, []string{"validate", "jwt", "token", "handle_build"}, QueryTerms("Where to validate the JWT token? handle_build, jwt"))
+	assert.Empty(t, QueryTerms("a to"))
+}
+
+func TestBestSnippet(t *testing.T) {
+	root := t.TempDir()
+	content := "package auth\n\nimport \"errors\"\n\nfunc Parse(raw string) {\n\tcheck()\n\tvalidate(token)\n\treturn\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "auth.go"), []byte(content), 0644))
+
+	snippet, err := BestSnippet(root, "/auth.go", 5, 9, []string{"validate", "token"}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, Snippet{Start: 6, Lines: []string{"\tcheck()", "\tvalidate(token)", "\treturn"}}, snippet)
+
+	snippet, err = BestSnippet(root, "/auth.go", 0, 0, []string{"errors"}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, snippet.Start, "context is clipped to the file")
+	assert.Len(t, snippet.Lines, 8)
+
+	snippet, err = BestSnippet(root, "/auth.go", 5, 9, []string{"missing"}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, Snippet{Start: 5, Lines: []string{"func Parse(raw string) {", "\tcheck()", "\tvalidate(token)"}}, snippet, "without matches the region starts at the chunk")
+
+	_, err = BestSnippet(root, "/gone.go", 1, 2, nil, 1)
+	assert.Error(t, err)
+}
+
+func TestSetSearchDefaults(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+	buildProject(t, "alpha", 1)
+
+	adaptive := false
+	stored, err := SetSearchDefaults("alpha", models.SearchDefaults{Limit: 20}, false)
+	require.NoError(t, err)
+	assert.Equal(t, models.SearchDefaults{Limit: 20}, stored)
+
+	stored, err = SetSearchDefaults("alpha", models.SearchDefaults{Adaptive: &adaptive}, false)
+	require.NoError(t, err)
+	assert.Equal(t, models.SearchDefaults{Limit: 20, Adaptive: &adaptive}, stored, "unset fields are kept")
+
+	info, err := Info("alpha")
+	require.NoError(t, err)
+	assert.Equal(t, stored, info.Search)
+
+	stored, err = SetSearchDefaults("alpha", models.SearchDefaults{Limit: 5}, true)
+	require.NoError(t, err)
+	assert.Equal(t, models.SearchDefaults{Limit: 5}, stored)
+
+	_, err = SetSearchDefaults("missing", models.SearchDefaults{Limit: 5}, false)
+	assert.ErrorIs(t, err, db.ErrProjectNotFound)
+}
+
+func TestRenderDocument(t *testing.T) {
+	assert.Equal(t, "/a.go Run\nfunc Run() {}", RenderDocument("", "/a.go", "Run", "func Run() {}"))
+	assert.Equal(t, "/a.go\nx", RenderDocument("", "/a.go", "", "x"))
+	assert.Equal(t, "search_document: Go /a.go Run\n{path} here",
+		RenderDocument("search_document: {language} {path} {symbol}\n{content}", "/a.go", "Run", "{path} here"),
+		"placeholders in the content are kept")
+	assert.Equal(t, "Rust", Language("/lib.RS"))
+	assert.Equal(t, "toml", Language("/Cargo.toml"))
+	assert.Equal(t, "", Language("/Makefile"))
+}
+
+func TestRenderQuery(t *testing.T) {
+	assert.Equal(t, "find me", RenderQuery("", "find me"))
+	assert.Equal(t, "search_query: find me", RenderQuery("search_query: {query}", "find me"))
+}
+
+func TestValidateTemplates(t *testing.T) {
+	assert.NoError(t, ValidateTemplates(models.Templates{}))
+	assert.NoError(t, ValidateTemplates(models.Templates{Document: "search_document: {content}", Query: "search_query: {query}"}))
+	assert.ErrorContains(t, ValidateTemplates(models.Templates{Document: "search_document: {path}"}), "{content}")
+	assert.ErrorContains(t, ValidateTemplates(models.Templates{Query: "search_query:"}), "{query}")
+}
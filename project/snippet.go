@@ -0,0 +1,152 @@
+package project
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// ReadLines returns lines start to end, 1-based and inclusive, of the file at
+// path relative to the project root as stored in the index. An end of zero
+// reads to the end of the file. Paths leaving the root are rejected, also
+// through symbolic links.
+func ReadLines(root, path string, start, end int) ([]string, error) {
+	if start < 1 {
+		start = 1
+	}
+	if end != 0 && end < start {
+		return nil, fmt.Errorf("invalid line range %d-%d", start, end)
+	}
+
+	full := AbsolutePath(root, path)
+	if !within(root, full) {
+		return nil, fmt.Errorf("path %s is outside of the project", path)
+	}
+	// The root itself may be reached through a link, so both are resolved.
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve project root %s: %w", root, err)
+	}
+	resolved, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if !within(resolvedRoot, resolved) {
+		return nil, fmt.Errorf("path %s is outside of the project", path)
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if n < start {
+			continue
+		}
+		if end != 0 && n > end {
+			break
+		}
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// within reports whether path is root or below it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// AbsolutePath returns the location on disk of path as stored in the index of
+// the project at root.
+func AbsolutePath(root, path string) string {
+	return filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(path, "/")))
+}
+
+// Snippet is a region of a file.
+type Snippet struct {
+	// Start is the 1-based line number of the first line.
+	Start int
+	Lines []string
+}
+
+// stopWords are query words too common to point at the matching region.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "for": true, "how": true, "in": true, "is": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "what": true, "where": true, "with": true,
+}
+
+// QueryTerms splits a query into the lower-cased words worth highlighting.
+func QueryTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if len(word) < 2 || stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// BestSnippet returns the line of lines start to end of path matching most
+// terms, with up to context lines on each side. An end of zero searches the
+// whole file. Without any matching line the region starts at start.
+func BestSnippet(root, path string, start, end int, terms []string, context int) (Snippet, error) {
+	if start < 1 {
+		start = 1
+	}
+	lines, err := ReadLines(root, path, start, end)
+	if err != nil {
+		return Snippet{}, err
+	}
+	if len(lines) == 0 {
+		return Snippet{Start: start}, nil
+	}
+	if context < 0 {
+		context = 0
+	}
+
+	best, bestMatches := -1, 0
+	for i, line := range lines {
+		if matches := countTerms(line, terms); matches > bestMatches {
+			best, bestMatches = i, matches
+		}
+	}
+
+	from, to := best-context, best+context
+	if best < 0 {
+		from, to = 0, 2*context
+	}
+	from = max(from, 0)
+	to = min(to, len(lines)-1)
+	return Snippet{Start: start + from, Lines: lines[from : to+1]}, nil
+}
+
+// countTerms returns how many of terms occur in line, ignoring case.
+func countTerms(line string, terms []string) int {
+	line = strings.ToLower(line)
+	n := 0
+	for _, term := range terms {
+		if strings.Contains(line, term) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// Template placeholders. Unknown placeholders are left as they are.
+const (
+	PlaceholderPath     = "{path}"
+	PlaceholderLanguage = "{language}"
+	PlaceholderSymbol   = "{symbol}"
+	PlaceholderContent  = "{content}"
+	PlaceholderQuery    = "{query}"
+)
+
+// languages maps file extensions to the language names used for
+// {language}. Other extensions are used without the dot.
+var languages = map[string]string{
+	".c":     "C",
+	".cc":    "C++",
+	".cpp":   "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".go":    "Go",
+	".h":     "C",
+	".hpp":   "C++",
+	".html":  "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".jsx":   "JavaScript",
+	".kt":    "Kotlin",
+	".lua":   "Lua",
+	".md":    "Markdown",
+	".php":   "PHP",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".scala": "Scala",
+	".sh":    "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".vue":   "Vue",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+}
+
+// Language returns the language of a file by its extension, empty when the
+// file has none.
+func Language(file string) string {
+	ext := strings.ToLower(path.Ext(file))
+	if name, ok := languages[ext]; ok {
+		return name
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// ValidateTemplates checks that the templates include the text they wrap, so
+// that chunks and queries are not embedded as constant strings.
+func ValidateTemplates(t models.Templates) error {
+	if t.Document != "" && !strings.Contains(t.Document, PlaceholderContent) {
+		return fmt.Errorf("document template must contain %s", PlaceholderContent)
+	}
+	if t.Query != "" && !strings.Contains(t.Query, PlaceholderQuery) {
+		return fmt.Errorf("query template must contain %s", PlaceholderQuery)
+	}
+	return nil
+}
+
+// RenderDocument returns the text embedded for a chunk of the file at path.
+// Without a template it is the path and symbol followed by the content.
+func RenderDocument(template, path, symbol, content string) string {
+	if template == "" {
+		if symbol == "" {
+			return fmt.Sprintf("%s\n%s", path, content)
+		}
+		return fmt.Sprintf("%s %s\n%s", path, symbol, content)
+	}
+	return strings.NewReplacer(
+		PlaceholderPath, path,
+		PlaceholderLanguage, Language(path),
+		PlaceholderSymbol, symbol,
+		PlaceholderContent, content,
+	).Replace(template)
+}
+
+// RenderQuery returns the text embedded for a search query, the query itself
+// without a template.
+func RenderQuery(template, query string) string {
+	if template == "" {
+		return query
+	}
+	return strings.ReplaceAll(template, PlaceholderQuery, query)
+}
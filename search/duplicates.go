@@ -0,0 +1,202 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+)
+
+// DefaultDuplicateThreshold is the cosine similarity from which chunks are
+// reported as near-duplicates.
+const DefaultDuplicateThreshold = 0.95
+
+// DefaultDuplicateNeighbours is how many nearest neighbours of each chunk are
+// compared.
+const DefaultDuplicateNeighbours = 10
+
+// Cluster is a group of near-identical chunks, linked by pairs with a
+// similarity of at least the threshold.
+type Cluster struct {
+	// Similarity is the lowest similarity of the pairs found in the cluster.
+	Similarity float64 `json:"similarity"`
+	// Chunks are ordered by file and line; their score is the highest
+	// similarity to another member.
+	Chunks []db.SearchResult `json:"chunks"`
+}
+
+// Files returns the number of distinct files in the cluster.
+func (c Cluster) Files() int {
+	files := make(map[int]bool)
+	for _, chunk := range c.Chunks {
+		files[chunk.ID] = true
+	}
+	return len(files)
+}
+
+// DuplicateReport is the result of Duplicates.
+type DuplicateReport struct {
+	Project   string    `json:"project"`
+	Threshold float64   `json:"threshold"`
+	Chunks    int       `json:"chunks"` // number of chunks compared
+	Clusters  []Cluster `json:"clusters"`
+}
+
+// Duplicates compares each chunk with its nearest neighbours in the stored
+// vectors and groups chunks with a cosine similarity of at least threshold
+// into clusters, largest first. No embedding calls are made.
+func (s *Searcher) Duplicates(ctx context.Context, threshold float64, neighbours int) (DuplicateReport, error) {
+	if threshold <= 0 || threshold > 1 {
+		return DuplicateReport{}, fmt.Errorf("threshold must be above 0 and at most 1, got %g", threshold)
+	}
+	if neighbours < 1 {
+		return DuplicateReport{}, fmt.Errorf("neighbours must be at least 1, got %d", neighbours)
+	}
+
+	ids, err := db.GetChunkIDs(s.db)
+	if err != nil {
+		return DuplicateReport{}, err
+	}
+
+	// Stored distances are cosine distances, 1 minus the similarity.
+	opts := db.SearchOptions{
+		MaxDistance: 1 - threshold,
+		MaxResults:  neighbours + 1, // the chunk finds itself
+	}
+	chunks := make(map[int64]db.SearchResult)
+	best := make(map[int64]float64)
+	clusters := newUnionFind()
+	linkSimilarity := make(map[int64]float64) // lowest pair similarity by cluster root
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return DuplicateReport{}, err
+		}
+
+		vector, err := db.GetChunkVector(s.db, id)
+		if err != nil {
+			return DuplicateReport{}, err
+		}
+		results, err := db.SearchWithThreshold(s.db, vector, opts)
+		if err != nil {
+			return DuplicateReport{}, err
+		}
+
+		for _, result := range results {
+			if result.ChunkID == id {
+				chunks[id] = result
+				continue
+			}
+			similarity := 1 - result.Distance
+			chunks[result.ChunkID] = result
+			best[id] = max(best[id], similarity)
+			best[result.ChunkID] = max(best[result.ChunkID], similarity)
+
+			a, b := clusters.find(id), clusters.find(result.ChunkID)
+			lowest := similarity
+			if v, ok := linkSimilarity[a]; ok {
+				lowest = min(lowest, v)
+			}
+			if v, ok := linkSimilarity[b]; ok {
+				lowest = min(lowest, v)
+			}
+			root := clusters.union(a, b)
+			linkSimilarity[root] = lowest
+		}
+	}
+
+	members := make(map[int64][]db.SearchResult)
+	for id, similarity := range best {
+		chunk := chunks[id]
+		chunk.Distance = similarity
+		root := clusters.find(id)
+		members[root] = append(members[root], chunk)
+	}
+
+	report := DuplicateReport{Project: s.project.Alias, Threshold: threshold, Chunks: len(ids), Clusters: []Cluster{}}
+	for root, chunks := range members {
+		sort.Slice(chunks, func(i, j int) bool {
+			if chunks[i].File != chunks[j].File {
+				return chunks[i].File < chunks[j].File
+			}
+			return chunks[i].StartLine < chunks[j].StartLine
+		})
+		report.Clusters = append(report.Clusters, Cluster{Similarity: linkSimilarity[root], Chunks: chunks})
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		a, b := report.Clusters[i], report.Clusters[j]
+		if len(a.Chunks) != len(b.Chunks) {
+			return len(a.Chunks) > len(b.Chunks)
+		}
+		if a.Similarity != b.Similarity {
+			return a.Similarity > b.Similarity
+		}
+		return a.Chunks[0].File < b.Chunks[0].File
+	})
+	return report, nil
+}
+
+// unionFind groups chunk ids into disjoint sets.
+type unionFind map[int64]int64
+
+func newUnionFind() unionFind {
+	return make(unionFind)
+}
+
+func (u unionFind) find(id int64) int64 {
+	parent, ok := u[id]
+	if !ok || parent == id {
+		return id
+	}
+	root := u.find(parent)
+	u[id] = root
+	return root
+}
+
+// union merges the sets of the roots a and b and returns the new root.
+func (u unionFind) union(a, b int64) int64 {
+	if a == b {
+		return a
+	}
+	if b < a {
+		a, b = b, a
+	}
+	u[b] = a
+	return a
+}
+
+// WriteDuplicates writes report to w in format, text or json.
+func WriteDuplicates(w io.Writer, format string, report DuplicateReport) error {
+	switch format {
+	case "", FormatText:
+		for i, cluster := range report.Clusters {
+			_, err := fmt.Fprintf(w, "Cluster %d: %d chunks in %d files, similarity >= %.4f\n", i+1, len(cluster.Chunks), cluster.Files(), cluster.Similarity)
+			if err != nil {
+				return err
+			}
+			for _, chunk := range cluster.Chunks {
+				location := chunk.File
+				if chunk.StartLine > 0 {
+					location = fmt.Sprintf("%s:%d-%d", chunk.File, chunk.StartLine, chunk.EndLine)
+				}
+				if chunk.Symbol != "" {
+					location += " \t " + chunk.Symbol
+				}
+				if _, err := fmt.Fprintf(w, "  %s \t (%f)\n", location, chunk.Distance); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return fmt.Errorf("unknown output format '%s', expected one of: %s, %s", format, FormatText, FormatJSON)
+}
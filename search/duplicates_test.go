@@ -0,0 +1,97 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicates(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "duplicates"), 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	vectors := map[string]models.Embedding{
+		"/a.go": {1, 0},
+		"/b.go": {0.9659, 0.2588}, // 15 degrees from a, a cosine of 0.966
+		"/c.go": {0.866, 0.5},     // 30 degrees from a, 15 from b
+		"/d.go": {0, 1},
+		"/e.go": {-1, 0},
+		"/f.go": {-1, 0},
+	}
+	for file, vector := range vectors {
+		_, err := db.SaveFileChunks(dbConn, models.File{File: file}, []models.Chunk{{Symbol: "F", StartLine: 1, EndLine: 3}}, []models.Embedding{vector})
+		require.NoError(t, err)
+	}
+
+	s := &Searcher{db: dbConn, project: &models.Project{Alias: "demo"}}
+	report, err := s.Duplicates(context.Background(), 0.95, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 6, report.Chunks)
+	require.Len(t, report.Clusters, 2)
+
+	// a and c are not similar enough, but both are close to b.
+	first := report.Clusters[0]
+	require.Len(t, first.Chunks, 3)
+	assert.Equal(t, []string{"/a.go", "/b.go", "/c.go"}, []string{first.Chunks[0].File, first.Chunks[1].File, first.Chunks[2].File})
+	assert.InDelta(t, 0.966, first.Similarity, 0.001)
+	assert.Equal(t, 3, first.Files())
+
+	second := report.Clusters[1]
+	assert.Equal(t, []string{"/e.go", "/f.go"}, []string{second.Chunks[0].File, second.Chunks[1].File})
+	assert.InDelta(t, 1.0, second.Similarity, 1e-6)
+
+	var out bytes.Buffer
+	require.NoError(t, WriteDuplicates(&out, FormatText, report))
+	assert.True(t, strings.HasPrefix(out.String(), "Cluster 1: 3 chunks in 3 files, similarity >= 0.9659"))
+	assert.Contains(t, out.String(), "  /e.go:1-3 \t F \t (1.000000)\n")
+
+	out.Reset()
+	require.NoError(t, WriteDuplicates(&out, FormatJSON, report))
+	var decoded DuplicateReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, "demo", decoded.Project)
+	assert.Len(t, decoded.Clusters, 2)
+
+	assert.Error(t, WriteDuplicates(&out, FormatCSV, report))
+	_, err = s.Duplicates(context.Background(), 1.5, 5)
+	assert.Error(t, err)
+}
+
+func TestDuplicatesThresholdIsCosine(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "duplicates_cosine"), 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	// a and b have a cosine similarity of 0.951, an L2 distance of 0.31.
+	vectors := map[string]models.Embedding{
+		"/a.go": {1, 0},
+		"/b.go": {0.951, 0.3092},
+		"/c.go": {0, 1},
+	}
+	for file, vector := range vectors {
+		_, err := db.SaveFileChunks(dbConn, models.File{File: file}, []models.Chunk{{StartLine: 1, EndLine: 3}}, []models.Embedding{vector})
+		require.NoError(t, err)
+	}
+
+	s := &Searcher{db: dbConn, project: &models.Project{}}
+	report, err := s.Duplicates(context.Background(), 0.95, 5)
+	require.NoError(t, err)
+	require.Len(t, report.Clusters, 1)
+	cluster := report.Clusters[0]
+	assert.Equal(t, []string{"/a.go", "/b.go"}, []string{cluster.Chunks[0].File, cluster.Chunks[1].File})
+	assert.InDelta(t, 0.951, cluster.Similarity, 0.001)
+	assert.InDelta(t, 0.951, cluster.Chunks[0].Distance, 0.001)
+
+	report, err = s.Duplicates(context.Background(), 0.96, 5)
+	require.NoError(t, err)
+	assert.Empty(t, report.Clusters)
+}
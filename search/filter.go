@@ -0,0 +1,80 @@
+package search
+
+import (
+	"path"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/file"
+)
+
+// filterOverFetch is how much the number of fetched results grows per round
+// while a filtered search has not found enough results.
+const filterOverFetch = 4
+
+// resultFilter keeps results whose path matches the path, extension and
+// exclude filters of a search.
+type resultFilter struct {
+	paths      *file.Ignorer
+	extensions map[string]bool
+	excludes   *file.Ignorer
+	skipFile   string
+}
+
+// newResultFilter returns the filter of config, or nil when it has none.
+func newResultFilter(config *Config) *resultFilter {
+	if len(config.Paths) == 0 && len(config.Extensions) == 0 && len(config.Excludes) == 0 && config.skipFile == "" {
+		return nil
+	}
+
+	f := &resultFilter{skipFile: config.skipFile}
+	if len(config.Paths) > 0 {
+		f.paths = file.NewIgnorer(config.Paths)
+	}
+	if len(config.Extensions) > 0 {
+		f.extensions = make(map[string]bool)
+		for _, ext := range config.Extensions {
+			f.extensions[normalizeExtension(ext)] = true
+		}
+	}
+	if len(config.Excludes) > 0 {
+		f.excludes = file.NewIgnorer(config.Excludes)
+	}
+	return f
+}
+
+// normalizeExtension accepts extensions with or without a leading dot.
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+func (f *resultFilter) match(filePath string) bool {
+	if f.skipFile != "" && filePath == f.skipFile {
+		return false
+	}
+	if f.paths != nil && !f.paths.IgnoredPath(filePath) {
+		return false
+	}
+	if f.extensions != nil && !f.extensions[normalizeExtension(path.Ext(filePath))] {
+		return false
+	}
+	if f.excludes != nil && f.excludes.IgnoredPath(filePath) {
+		return false
+	}
+	return true
+}
+
+// keep reports whether result passes the filter.
+func (f *resultFilter) keep(result db.SearchResult) bool {
+	return f.match(result.File)
+}
+
+func (f *resultFilter) apply(results []db.SearchResult) []db.SearchResult {
+	var kept []db.SearchResult
+	for _, result := range results {
+		if f.match(result.File) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultFilter(t *testing.T) {
+	assert.Nil(t, newResultFilter(&Config{}))
+
+	f := newResultFilter(&Config{Paths: []string{"internal/**"}, Extensions: []string{".Go", "ts"}, Excludes: []string{"**/*_test.go"}})
+	require.NotNil(t, f)
+	assert.True(t, f.match("/internal/auth/login.go"))
+	assert.True(t, f.match("/internal/web/app.ts"))
+	assert.False(t, f.match("/internal/auth/login_test.go"))
+	assert.False(t, f.match("/internal/README.md"))
+	assert.False(t, f.match("/cmd/root.go"))
+}
+
+func TestSearchFilteredFillsLimit(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "filter"), 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+	if !db.KeywordSearchAvailable() {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+
+	// Vendored files rank first, the filtered ones only show up when
+	// fetching beyond the first round.
+	for i := 0; i < 40; i++ {
+		save(t, dbConn, fmt.Sprintf("/vendor/lib%d.go", i), strings.Repeat("token ", 5))
+	}
+	for i := 0; i < 5; i++ {
+		save(t, dbConn, fmt.Sprintf("/internal/auth%d.go", i), "token and lots of other words that dilute the match")
+	}
+	save(t, dbConn, "/internal/auth_test.go", "token")
+
+	s := &Searcher{db: dbConn, project: &models.Project{}}
+	config := &Config{Query: "token", Mode: ModeKeyword, SearchDefaults: models.SearchDefaults{Limit: 3}, Paths: []string{"internal/**"}, Excludes: []string{"*_test.go"}}
+	results, err := s.Search(context.Background(), config)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for _, r := range results {
+		assert.Contains(t, r.File, "/internal/auth")
+		assert.NotContains(t, r.File, "_test")
+	}
+
+	config.Limit = 10
+	results, err = s.Search(context.Background(), config)
+	require.NoError(t, err)
+	assert.Len(t, results, 5, "stops once fetching more finds nothing new")
+}
+
+func TestSearchFilteredVector(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "filter_vector"), 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	// Vendored files match the query exactly, the filtered ones are behind
+	// a gap that the adaptive threshold would cut at.
+	saveVector := func(file string, vector models.Embedding) {
+		_, err := db.SaveFileChunks(dbConn, models.File{File: file}, []models.Chunk{{StartLine: 1, EndLine: 1}}, []models.Embedding{vector})
+		require.NoError(t, err)
+	}
+	for i := 0; i < 5; i++ {
+		saveVector(fmt.Sprintf("/vendor/lib%d.go", i), models.Embedding{1, 0})
+	}
+	saveVector("/internal/auth.go", models.Embedding{0.95, 0.31})
+	saveVector("/internal/login.go", models.Embedding{0.94, 0.34})
+
+	s := &Searcher{db: dbConn, project: &models.Project{}, embedder: &fixedEmbedder{vector: models.Embedding{1, 0}}}
+	config := &Config{Query: "token", Mode: ModeVector, SearchDefaults: models.SearchDefaults{Limit: 3}, Paths: []string{"internal/**"}}
+	results, err := s.Search(context.Background(), config)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "/internal/auth.go", results[0].File)
+	assert.Equal(t, "/internal/login.go", results[1].File)
+}
+
+func save(t *testing.T, dbConn *sql.DB, file, content string) {
+	t.Helper()
+	_, err := db.SaveFileChunks(dbConn, models.File{File: file}, []models.Chunk{{StartLine: 1, EndLine: 1, Content: content}}, []models.Embedding{{1, 0}})
+	require.NoError(t, err)
+}
@@ -0,0 +1,299 @@
+package search
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/project"
+)
+
+// Output formats.
+const (
+	FormatText    = "text"
+	FormatJSON    = "json"
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatVimgrep = "vimgrep"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatText, FormatJSON, FormatJSONL, FormatCSV, FormatVimgrep}
+
+// Report is a finished search ready to be written out.
+type Report struct {
+	Project string
+	// Root is the project source path, used to resolve absolute paths.
+	Root string
+	// Roots are the source paths by alias of a cross-project search, whose
+	// results carry their project.
+	Roots map[string]string
+	Query string
+	// Mode is the mode the search ran in, see ResolveMode.
+	Mode    string
+	Results []db.SearchResult
+	// Terms are highlighted in snippets, nil for the words of Query.
+	Terms []string
+	// Warnings explain why results may be missing, see KeywordIndexWarning.
+	Warnings []string
+
+	// Snippets prints the best matching region of each result beneath it in
+	// text format, with Context lines around the best matching line.
+	Snippets bool
+	Context  int
+	// Color highlights query terms in snippets with ANSI escape codes.
+	Color bool
+}
+
+// Result is the machine-readable form of a search result.
+type Result struct {
+	Rank         int      `json:"rank"`
+	Project      string   `json:"project"`
+	Query        string   `json:"query"`
+	Path         string   `json:"path"`
+	AbsolutePath string   `json:"absolute_path"`
+	Score        float64  `json:"score"`
+	Distance     *float64 `json:"distance,omitempty"` // cosine distance to the query, when ranked by vector search
+	StartLine    int      `json:"start_line,omitempty"`
+	EndLine      int      `json:"end_line,omitempty"`
+	Symbol       string   `json:"symbol,omitempty"`
+	Hits         int      `json:"hits,omitempty"`
+}
+
+// Rows returns the results of the report in their machine-readable form,
+// ranked from 1.
+func (r Report) Rows() []Result {
+	rows := make([]Result, 0, len(r.Results))
+	for i, result := range r.Results {
+		row := Result{
+			Rank:         i + 1,
+			Project:      r.project(result),
+			Query:        r.Query,
+			Path:         result.File,
+			AbsolutePath: project.AbsolutePath(r.root(result), result.File),
+			Score:        result.Distance,
+			StartLine:    result.StartLine,
+			EndLine:      result.EndLine,
+			Symbol:       result.Symbol,
+			Hits:         result.Hits,
+			Distance:     result.VectorDistance,
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// project returns the alias of the project result belongs to.
+func (r Report) project(result db.SearchResult) string {
+	if result.Project != "" {
+		return result.Project
+	}
+	return r.Project
+}
+
+// root returns the source path of the project result belongs to.
+func (r Report) root(result db.SearchResult) string {
+	if root, ok := r.Roots[result.Project]; ok {
+		return root
+	}
+	return r.Root
+}
+
+// Write writes the results of report to w in format.
+func Write(w io.Writer, format string, report Report) error {
+	switch format {
+	case "", FormatText:
+		return writeText(w, report)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report.Rows())
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, row := range report.Rows() {
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		return writeCSV(w, report)
+	case FormatVimgrep:
+		return writeVimgrep(w, report)
+	}
+	return unknownFormat(format)
+}
+
+// ValidateFormat reports an error for an unsupported output format.
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if format == f {
+			return nil
+		}
+	}
+	return unknownFormat(format)
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown output format '%s', expected one of: %s", format, strings.Join(Formats, ", "))
+}
+
+func writeText(w io.Writer, report Report) error {
+	terms := report.Terms
+	if terms == nil {
+		terms = project.QueryTerms(report.Query)
+	}
+	for _, result := range report.Results {
+		if result.Project != "" {
+			if _, err := fmt.Fprintf(w, "[%s] ", result.Project); err != nil {
+				return err
+			}
+		}
+		var err error
+		switch {
+		case result.Hits > 1:
+			_, err = fmt.Fprintf(w, "%s \t (%f %d, %d matches)\n", result.Location(), result.Distance, result.ID, result.Hits)
+		case result.Symbol != "":
+			_, err = fmt.Fprintf(w, "%s \t %s \t (%f %d)\n", result.Location(), result.Symbol, result.Distance, result.ID)
+		default:
+			_, err = fmt.Fprintf(w, "%s \t (%f %d)\n", result.Location(), result.Distance, result.ID)
+		}
+		if err != nil {
+			return err
+		}
+		if report.Snippets {
+			if err := writeSnippet(w, report, result, terms); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSnippet prints the best matching region of result, read from the
+// project sources, followed by a blank line.
+func writeSnippet(w io.Writer, report Report, result db.SearchResult, terms []string) error {
+	snippet, err := project.BestSnippet(report.root(result), result.File, result.StartLine, result.EndLine, terms, report.Context)
+	if err != nil {
+		_, err = fmt.Fprintf(w, "  (snippet unavailable: %v)\n\n", err)
+		return err
+	}
+
+	for i, line := range snippet.Lines {
+		if report.Color {
+			line = highlight(line, terms)
+		}
+		if _, err := fmt.Fprintf(w, "%6d | %s\n", snippet.Start+i, line); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+const (
+	highlightStart = "\x1b[1;33m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// highlight wraps occurrences of terms in line with ANSI bold yellow,
+// ignoring case.
+func highlight(line string, terms []string) string {
+	lower := strings.ToLower(line)
+	if len(lower) != len(line) || len(terms) == 0 {
+		// Lower-casing changed byte offsets, matches cannot be mapped back.
+		return line
+	}
+
+	marked := make([]bool, len(line))
+	for _, term := range terms {
+		for from := 0; ; {
+			i := strings.Index(lower[from:], term)
+			if i < 0 {
+				break
+			}
+			for j := from + i; j < from+i+len(term); j++ {
+				marked[j] = true
+			}
+			from += i + len(term)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(highlightStart)
+		}
+		b.WriteByte(line[i])
+		if marked[i] && (i == len(line)-1 || !marked[i+1]) {
+			b.WriteString(highlightEnd)
+		}
+	}
+	return b.String()
+}
+
+func writeCSV(w io.Writer, report Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"rank", "project", "query", "path", "absolute_path", "score", "distance", "start_line", "end_line", "symbol", "hits"}); err != nil {
+		return err
+	}
+	for _, row := range report.Rows() {
+		distance := ""
+		if row.Distance != nil {
+			distance = formatFloat(*row.Distance)
+		}
+		err := cw.Write([]string{
+			strconv.Itoa(row.Rank),
+			row.Project,
+			row.Query,
+			row.Path,
+			row.AbsolutePath,
+			formatFloat(row.Score),
+			distance,
+			formatInt(row.StartLine),
+			formatInt(row.EndLine),
+			row.Symbol,
+			formatInt(row.Hits),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeVimgrep writes results as file:line:column:text, the format of
+// vimgrep and most editor quickfix lists.
+func writeVimgrep(w io.Writer, report Report) error {
+	for _, row := range report.Rows() {
+		line := row.StartLine
+		if line < 1 {
+			line = 1
+		}
+		text := fmt.Sprintf("score %.4f", row.Score)
+		if row.Symbol != "" {
+			text = row.Symbol + " " + text
+		}
+		if _, err := fmt.Fprintf(w, "%s:%d:1:%s\n", row.AbsolutePath, line, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+// formatInt leaves unknown (zero) values empty.
+func formatInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
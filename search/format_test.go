@@ -0,0 +1,170 @@
+package search
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport(mode string) Report {
+	report := Report{
+		Project: "demo",
+		Root:    filepath.FromSlash("/src/demo"),
+		Query:   "build handler",
+		Mode:    mode,
+		Results: []db.SearchResult{
+			{ID: 1, File: "/cmd/root.go", Distance: 0.75, ChunkID: 4, Symbol: "handleBuild", StartLine: 120, EndLine: 140},
+			{ID: 2, File: "/README.md", Distance: 0.5, Hits: 3},
+		},
+	}
+	if mode != ModeKeyword {
+		// Only the first result was ranked by vector search.
+		distance := 0.25
+		report.Results[0].VectorDistance = &distance
+	}
+	return report
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatJSON, testReport(ModeVector)))
+
+	var rows []map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &rows))
+	require.Len(t, rows, 2)
+	assert.Equal(t, float64(1), rows[0]["rank"])
+	assert.Equal(t, "demo", rows[0]["project"])
+	assert.Equal(t, "build handler", rows[0]["query"])
+	assert.Equal(t, "/cmd/root.go", rows[0]["path"])
+	assert.Equal(t, filepath.Join(filepath.FromSlash("/src/demo"), "cmd", "root.go"), rows[0]["absolute_path"])
+	assert.Equal(t, 0.75, rows[0]["score"])
+	assert.Equal(t, 0.25, rows[0]["distance"])
+	assert.Equal(t, float64(120), rows[0]["start_line"])
+	assert.Equal(t, float64(140), rows[0]["end_line"])
+	assert.Equal(t, "handleBuild", rows[0]["symbol"])
+
+	assert.Equal(t, float64(2), rows[1]["rank"])
+	assert.NotContains(t, rows[1], "start_line")
+	assert.NotContains(t, rows[1], "symbol")
+	assert.Equal(t, float64(3), rows[1]["hits"])
+
+	out.Reset()
+	require.NoError(t, Write(&out, FormatJSON, Report{Project: "demo"}))
+	assert.Equal(t, "[]\n", out.String())
+}
+
+func TestWriteJSONL(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatJSONL, testReport(ModeHybrid)))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var row map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &row))
+	assert.Equal(t, "/cmd/root.go", row["path"])
+	assert.Equal(t, 0.25, row["distance"], "hybrid results keep their vector distance")
+	var second map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.NotContains(t, second, "distance", "distance is only known for vector ranked results")
+}
+
+func TestWriteCSV(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatCSV, testReport(ModeKeyword)))
+
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"rank", "project", "query", "path", "absolute_path", "score", "distance", "start_line", "end_line", "symbol", "hits"}, records[0])
+	assert.Equal(t, []string{"1", "demo", "build handler", "/cmd/root.go", filepath.Join(filepath.FromSlash("/src/demo"), "cmd", "root.go"), "0.750000", "", "120", "140", "handleBuild", ""}, records[1])
+	assert.Equal(t, "3", records[2][10])
+}
+
+func TestWriteVimgrep(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatVimgrep, testReport(ModeVector)))
+
+	root := filepath.FromSlash("/src/demo")
+	assert.Equal(t,
+		filepath.Join(root, "cmd", "root.go")+":120:1:handleBuild score 0.7500\n"+
+			filepath.Join(root, "README.md")+":1:1:score 0.5000\n",
+		out.String())
+}
+
+func TestWriteText(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatText, testReport(ModeVector)))
+	assert.Equal(t, "/cmd/root.go:120 \t handleBuild \t (0.750000 1)\n/README.md \t (0.500000 2, 3 matches)\n", out.String())
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, format := range Formats {
+		assert.NoError(t, ValidateFormat(format))
+	}
+	err := ValidateFormat("xml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown output format 'xml'")
+	assert.Error(t, Write(&bytes.Buffer{}, "xml", Report{}))
+}
+
+func TestWriteTextSnippets(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc handleBuild() {\n\tbuild()\n}\n"), 0644))
+
+	report := Report{
+		Project:  "demo",
+		Root:     root,
+		Query:    "handleBuild",
+		Results:  []db.SearchResult{{ID: 1, File: "/main.go", Distance: 0.5, Symbol: "handleBuild", StartLine: 3, EndLine: 5}, {ID: 2, File: "/gone.go", Distance: 0.25}},
+		Snippets: true,
+		Context:  1,
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatText, report))
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, "/main.go:3 \t handleBuild \t (0.500000 1)", lines[0])
+	assert.Equal(t, "     3 | func handleBuild() {", lines[1])
+	assert.Equal(t, "     4 | \tbuild()", lines[2])
+	assert.Equal(t, "", lines[3])
+	assert.Equal(t, "/gone.go \t (0.250000 2)", lines[4])
+	assert.Contains(t, lines[5], "snippet unavailable")
+
+	out.Reset()
+	report.Color = true
+	report.Results = report.Results[:1]
+	require.NoError(t, Write(&out, FormatText, report))
+	assert.Contains(t, out.String(), "func "+highlightStart+"handleBuild"+highlightEnd+"() {")
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, "x "+highlightStart+"JwtToken"+highlightEnd+" y", highlight("x JwtToken y", []string{"jwt", "token"}))
+	assert.Equal(t, "nothing", highlight("nothing", []string{"jwt"}))
+	assert.Equal(t, "İjwt", highlight("İjwt", []string{"jwt"}), "lines changing length when lower-cased are left alone")
+}
+
+func TestWriteCrossProject(t *testing.T) {
+	report := Report{
+		Query: "login",
+		Roots: map[string]string{"web": filepath.FromSlash("/src/web")},
+		Results: []db.SearchResult{
+			{ID: 3, File: "/login.ts", Distance: 0.5, StartLine: 7, Project: "web"},
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatText, report))
+	assert.Equal(t, "[web] /login.ts:7 \t (0.500000 3)\n", out.String())
+
+	rows := report.Rows()
+	assert.Equal(t, "web", rows[0].Project)
+	assert.Equal(t, filepath.Join(filepath.FromSlash("/src/web"), "login.ts"), rows[0].AbsolutePath)
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+// RunProjects runs the query of config in each of aliases and merges the
+// results into one ranking, best first, with each result tagged with its
+// project. The query is embedded once per distinct client, model and query
+// template. The ProjectAlias of config is ignored.
+//
+// Scores are normalized to be comparable across projects, see
+// normalizeScore. The merged ranking has config.Limit results, or
+// DefaultLimit when it is not set.
+func RunProjects(ctx context.Context, aliases []string, config *Config) (Report, error) {
+	mode, err := validate(config)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Query: config.Query, Mode: mode, Roots: make(map[string]string)}
+	if warning := FallbackWarning(config.Mode); warning != "" {
+		report.Warnings = append(report.Warnings, warning)
+	}
+	var searchers []*Searcher
+	defer func() {
+		for _, s := range searchers {
+			s.Close()
+		}
+	}()
+	for _, alias := range aliases {
+		if _, ok := report.Roots[alias]; ok {
+			continue
+		}
+		s, err := Open(alias, config.ClientOptions)
+		if err != nil {
+			return Report{}, fmt.Errorf("project '%s': %w", alias, err)
+		}
+		searchers = append(searchers, s)
+		report.Roots[alias] = s.Project().Path
+	}
+
+	// Projects indexed with the same model and query template share the
+	// query embedding. Each project still checks the probe against its own
+	// fingerprint.
+	type modelKey struct{ client, model, input string }
+	type queryEmbedding struct {
+		query []float32
+		probe models.Embedding
+	}
+	embedded := make(map[modelKey]queryEmbedding)
+	for _, s := range searchers {
+		alias := s.Project().Alias
+		if warning := KeywordIndexWarning(s.Project(), mode); warning != "" {
+			report.Warnings = append(report.Warnings, warning)
+		}
+
+		var vector []float32
+		if mode != ModeKeyword {
+			key := modelKey{s.Project().Client, s.Project().Model, s.queryInput(config.Query)}
+			e, ok := embedded[key]
+			if !ok {
+				e.query, e.probe, err = s.embedQuery(ctx, key.input)
+				if err != nil {
+					return Report{}, fmt.Errorf("project '%s': %w", alias, err)
+				}
+				embedded[key] = e
+			}
+			if err := s.checkModel(e.probe); err != nil {
+				return Report{}, err
+			}
+			vector = e.query
+		}
+
+		results, err := s.search(config, mode, vector)
+		if err != nil {
+			return Report{}, fmt.Errorf("project '%s': %w", alias, err)
+		}
+		for _, result := range results {
+			result.Project = alias
+			result.Distance = normalizeScore(mode, result.Distance)
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		return report.Results[i].Distance > report.Results[j].Distance
+	})
+	limit := config.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(report.Results) > limit {
+		report.Results = report.Results[:limit]
+	}
+	return report, nil
+}
+
+// normalizeScore maps a score of mode to [0, 1] without depending on the
+// other results of the project, so that scores of different projects can be
+// compared. Vector scores are cosine similarities c in [-1, 1] mapped to
+// (1+c)/2, keyword scores are BM25 values s mapped to s/(1+s) and hybrid
+// scores are relative to a result ranked first by both rankings.
+func normalizeScore(mode string, score float64) float64 {
+	switch mode {
+	case ModeKeyword:
+		if score <= 0 {
+			return 0
+		}
+		return score / (1 + score)
+	case ModeHybrid:
+		return score / db.MaxFusedScore
+	}
+	return (1 + score) / 2
+}
@@ -0,0 +1,128 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupProject(t *testing.T, alias string, files map[string]string) {
+	t.Helper()
+	dbConn, err := db.SetupDatabase(alias, 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	require.NoError(t, db.UpsertProject(dbConn, models.Project{Alias: alias, Path: "/src/" + alias, Client: "ollama", Model: "m"}))
+	require.NoError(t, db.RegisterProject(alias, "/src/"+alias))
+	for file, content := range files {
+		save(t, dbConn, file, content)
+	}
+}
+
+func TestRunProjects(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+	setupProject(t, "backend", map[string]string{"/auth.go": "token token token", "/db.go": "database"})
+	setupProject(t, "frontend", map[string]string{"/login.ts": "token"})
+	if !db.KeywordSearchAvailable() {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+
+	config, err := ParseQueryConfig([]string{"token"})
+	require.NoError(t, err)
+	config.Mode = ModeKeyword
+
+	report, err := RunProjects(context.Background(), []string{"frontend", "backend", "frontend"}, config)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"backend": "/src/backend", "frontend": "/src/frontend"}, report.Roots)
+	require.Len(t, report.Results, 2)
+	for _, r := range report.Results {
+		assert.Greater(t, r.Distance, 0.0)
+		assert.Less(t, r.Distance, 1.0)
+	}
+	assert.GreaterOrEqual(t, report.Results[0].Distance, report.Results[1].Distance)
+	assert.ElementsMatch(t, []string{"backend", "frontend"}, []string{report.Results[0].Project, report.Results[1].Project})
+
+	rows := report.Rows()
+	for _, row := range rows {
+		assert.Equal(t, report.Roots[row.Project]+row.Path, row.AbsolutePath)
+	}
+
+	config.Limit = 1
+	report, err = RunProjects(context.Background(), []string{"frontend", "backend"}, config)
+	require.NoError(t, err)
+	assert.Len(t, report.Results, 1)
+
+	_, err = RunProjects(context.Background(), []string{"backend", "missing"}, config)
+	assert.ErrorIs(t, err, db.ErrProjectNotFound)
+	assert.ErrorContains(t, err, "project 'missing'")
+}
+
+// TestRunProjectsScoreScale checks that the merged scores of vector and
+// hybrid searches stay within 0..1, also for opposite vectors.
+func TestRunProjectsScoreScale(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+	ctx := context.Background()
+	embed := func(text string) models.Embedding {
+		vectors, err := client.Hash{}.Embed(ctx, []string{text})
+		require.NoError(t, err)
+		return vectors[0]
+	}
+	query := embed("token")
+	opposite := make(models.Embedding, len(query))
+	for i, x := range query {
+		opposite[i] = -x
+	}
+	setup := func(alias string, files map[string]string, vectors map[string]models.Embedding) {
+		dbConn, err := db.SetupDatabase(alias, client.HashDimensions)
+		require.NoError(t, err)
+		defer dbConn.Close()
+		require.NoError(t, db.UpsertProject(dbConn, models.Project{Alias: alias, Path: "/src/" + alias, Client: "hash", Model: "hash"}))
+		require.NoError(t, db.RegisterProject(alias, "/src/"+alias))
+		for file, content := range files {
+			_, err := db.SaveFileChunks(dbConn, models.File{File: file}, []models.Chunk{{StartLine: 1, EndLine: 1, Content: content}}, []models.Embedding{vectors[file]})
+			require.NoError(t, err)
+		}
+	}
+	setup("backend", map[string]string{"/auth.go": "token", "/other.go": "unrelated"}, map[string]models.Embedding{"/auth.go": query, "/other.go": opposite})
+	setup("frontend", map[string]string{"/login.ts": "token token"}, map[string]models.Embedding{"/login.ts": embed("login")})
+
+	adaptive := false
+	minResults := 10
+	for _, mode := range []string{ModeVector, ModeHybrid} {
+		if mode == ModeHybrid && !db.KeywordSearchAvailable() {
+			continue
+		}
+		config, err := ParseQueryConfig([]string{"token"})
+		require.NoError(t, err)
+		config.Mode = mode
+		config.Adaptive = &adaptive
+		config.MinResults = &minResults
+
+		report, err := RunProjects(ctx, []string{"backend", "frontend"}, config)
+		require.NoError(t, err, mode)
+		require.Len(t, report.Results, 3, mode)
+		for _, r := range report.Results {
+			assert.GreaterOrEqual(t, r.Distance, 0.0, "%s %s", mode, r.File)
+			assert.LessOrEqual(t, r.Distance, 1.0, "%s %s", mode, r.File)
+		}
+		assert.Equal(t, "/auth.go", report.Results[0].File, mode)
+		if mode == ModeVector {
+			assert.InDelta(t, 1, report.Results[0].Distance, 1e-6)
+			assert.Equal(t, "/other.go", report.Results[2].File)
+			assert.InDelta(t, 0, report.Results[2].Distance, 1e-6)
+		}
+	}
+}
+
+func TestNormalizeScore(t *testing.T) {
+	assert.Equal(t, 0.9, normalizeScore(ModeVector, 0.8))
+	assert.Equal(t, 0.0, normalizeScore(ModeVector, -1))
+	assert.Equal(t, 0.75, normalizeScore(ModeKeyword, 3))
+	assert.Equal(t, 0.0, normalizeScore(ModeKeyword, -1))
+	assert.InDelta(t, 1.0, normalizeScore(ModeHybrid, db.MaxFusedScore), 1e-9)
+}
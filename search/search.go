@@ -5,16 +5,84 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/andrejsstepanovs/codesearch/client"
 	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/andrejsstepanovs/codesearch/project"
 )
 
+// fileLevelOverFetch is how many chunks are fetched per requested file result.
+const fileLevelOverFetch = 5
+
+// hybridOverFetch is how many results each ranking contributes per requested
+// hybrid result, so that fusion can promote results ranked low by one of them.
+const hybridOverFetch = 3
+
+// Search modes.
+const (
+	ModeVector  = "vector"
+	ModeKeyword = "keyword"
+	ModeHybrid  = "hybrid"
+)
+
+// Modes lists the supported search modes.
+var Modes = []string{ModeVector, ModeKeyword, ModeHybrid}
+
+// DefaultLimit is the default maximum number of results.
+const DefaultLimit = 10
+
+// DefaultMinSimilarity drops vector results that are barely related.
+const DefaultMinSimilarity = 0.03
+
+// DefaultMinResults is how many vector results are returned even when none
+// pass the thresholds.
+const DefaultMinResults = 1
+
+// builtinDefaults apply when neither the search nor the project set a value.
+var builtinDefaults = models.SearchDefaults{
+	Limit:         DefaultLimit,
+	MinSimilarity: ptr(DefaultMinSimilarity),
+	MinResults:    ptr(DefaultMinResults),
+	Adaptive:      ptr(true),
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// DefaultVectorWeight balances vector and keyword rankings equally in hybrid mode.
+const DefaultVectorWeight = 0.5
+
 // Config holds the configuration for a search operation.
 type Config struct {
 	ProjectAlias string
 	Query        string
+	// ClientOptions override how the embedding provider is reached.
+	ClientOptions client.Options
+	// FileLevel aggregates chunk matches into one result per file.
+	FileLevel bool
+	// SearchDefaults override the defaults of the project for this search.
+	// Limit is the maximum number of results, the thresholds only apply to
+	// the vector ranking.
+	models.SearchDefaults
+	// Mode is one of Modes, empty for hybrid.
+	Mode string
+	// VectorWeight is the share of the vector ranking in hybrid mode, in [0, 1].
+	VectorWeight float64
+	// Paths keeps results under paths matching any of these gitignore-style
+	// patterns, e.g. "internal/**".
+	Paths []string
+	// Extensions keeps results with one of these file extensions, e.g. "go".
+	Extensions []string
+	// Excludes drops results matching any of these gitignore-style patterns.
+	Excludes []string
+
+	// skipFile drops results of this indexed file, the target of Similar.
+	skipFile string
 }
 
 // ParseConfig parses command line arguments into a Config struct.
@@ -23,47 +91,358 @@ func ParseConfig(args []string) (*Config, error) {
 		return nil, fmt.Errorf("at least 2 arguments required (alias, query)")
 	}
 
-	config := &Config{
-		ProjectAlias: args[0],
-		Query:        strings.Join(args[1:], " "),
+	config, err := ParseQueryConfig(args[1:])
+	if err != nil {
+		return nil, err
 	}
+	config.ProjectAlias = args[0]
+	return config, nil
+}
 
-	config.Query = strings.TrimSpace(config.Query)
+// ParseQueryConfig parses query arguments into a Config without a project,
+// as used by cross-project searches.
+func ParseQueryConfig(args []string) (*Config, error) {
+	config := &Config{
+		Query:        strings.TrimSpace(strings.Join(args, " ")),
+		Mode:         ModeVector,
+		VectorWeight: DefaultVectorWeight,
+	}
 	if config.Query == "" {
 		return nil, fmt.Errorf("search query cannot be empty")
 	}
-
 	return config, nil
 }
 
-// Run executes a search operation based on the provided config.
-func Run(ctx context.Context, config *Config) ([]db.SearchResult, error) {
-	dbConn, err := db.SetupDatabase(config.ProjectAlias, 0)
+// Searcher runs searches against an open project database, reusing the
+// connection and the embedding client across queries.
+type Searcher struct {
+	db       *sql.DB
+	project  *models.Project
+	embedder client.Embedder
+	// database is the file the searcher was opened on, to notice a rebuild
+	// into a new file, see Stale.
+	database os.FileInfo
+
+	checkMu sync.Mutex // CheckModel may record the identity in project
+}
+
+// Open opens the database of a project for searching.
+func Open(alias string, opts client.Options) (*Searcher, error) {
+	dbConn, err := db.SetupDatabase(alias, 0)
 	if err != nil {
+		if errors.Is(err, db.ErrProjectNotFound) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
-	defer dbConn.Close()
 
-	// Validate project exists
-	proj, err := db.GetProjectByAlias(dbConn, config.ProjectAlias)
+	proj, err := db.GetProjectByAlias(dbConn, alias)
 	if err != nil {
+		dbConn.Close()
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("project with alias '%s' not found", config.ProjectAlias)
+			return nil, fmt.Errorf("%w: '%s'", db.ErrProjectNotFound, alias)
 		}
 		return nil, fmt.Errorf("error retrieving project: %w", err)
 	}
 
-	embedding, err := client.Embeddings(ctx, proj.Client, proj.Model, config.Query)
+	embedder, err := client.New(proj.Client, proj.Model, opts)
 	if err != nil {
-		return nil, fmt.Errorf("error generating embeddings for query: %w", err)
+		dbConn.Close()
+		return nil, fmt.Errorf("error creating embedding client: %w", err)
 	}
 
-	minSimilarity := 0.03
-	limit := 10
-	results, err := db.SearchWithSimilarity(dbConn, embedding.GetEmbeddings().Float32(), minSimilarity, limit)
+	database, err := databaseFile(alias)
 	if err != nil {
-		return nil, fmt.Errorf("error searching for similar files: %w", err)
+		dbConn.Close()
+		return nil, err
 	}
 
+	return &Searcher{db: dbConn, project: proj, embedder: embedder, database: database}, nil
+}
+
+// databaseFile returns the file information of the database of alias.
+func databaseFile(alias string) (os.FileInfo, error) {
+	entry, err := db.LookupProject(alias)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(entry.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database of project '%s': %w", alias, err)
+	}
+	return info, nil
+}
+
+// Stale reports whether the project changed since the searcher was opened:
+// it was removed, rebuilt or synced, or its model changed. A long-lived
+// searcher that is stale should be closed and opened again, as it keeps
+// using the project settings and embedding client it was opened with.
+func (s *Searcher) Stale() (bool, error) {
+	database, err := databaseFile(s.project.Alias)
+	if errors.Is(err, db.ErrProjectNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !os.SameFile(database, s.database) {
+		return true, nil
+	}
+
+	current, err := db.GetProjectByAlias(s.db, s.project.Alias)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+	p := s.project
+	return !current.SyncedAt.Equal(p.SyncedAt) || current.Client != p.Client || current.Model != p.Model ||
+		current.Dimensions != p.Dimensions || current.Fingerprint != p.Fingerprint || current.Templates != p.Templates, nil
+}
+
+// Project returns the metadata of the project being searched.
+func (s *Searcher) Project() *models.Project {
+	return s.project
+}
+
+// Close closes the project database.
+func (s *Searcher) Close() error {
+	return s.db.Close()
+}
+
+// Run executes a search operation based on the provided config.
+func Run(ctx context.Context, config *Config) ([]db.SearchResult, error) {
+	searcher, err := Open(config.ProjectAlias, config.ClientOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer searcher.Close()
+
+	return searcher.Search(ctx, config)
+}
+
+// Search runs the query of config. Project and client options of config are
+// ignored in favour of those the searcher was opened with.
+func (s *Searcher) Search(ctx context.Context, config *Config) ([]db.SearchResult, error) {
+	mode, err := validate(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var vector []float32
+	if mode != ModeKeyword {
+		query, probe, err := s.embedQuery(ctx, s.queryInput(config.Query))
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkModel(probe); err != nil {
+			return nil, err
+		}
+		vector = query
+	}
+	return s.search(config, mode, vector)
+}
+
+// validate checks the settings of config and returns the mode to search in.
+func validate(config *Config) (string, error) {
+	if err := ValidateDefaults(config.SearchDefaults); err != nil {
+		return "", err
+	}
+	if config.VectorWeight < 0 || config.VectorWeight > 1 {
+		return "", fmt.Errorf("vector weight must be between 0 and 1, got %g", config.VectorWeight)
+	}
+	return ResolveMode(config.Mode)
+}
+
+// search ranks the chunks for a validated config in mode. vector is the
+// query embedding, nil in keyword mode.
+func (s *Searcher) search(config *Config, mode string, vector []float32) ([]db.SearchResult, error) {
+	settings := config.SearchDefaults.Or(s.project.Search).Or(builtinDefaults)
+
+	limit := settings.Limit
+	fetch := limit
+	if config.FileLevel {
+		// Several chunks of one file may match, fetch more to fill the limit.
+		fetch = limit * fileLevelOverFetch
+	}
+
+	filter := newResultFilter(config)
+	if filter == nil {
+		results, err := s.rank(mode, config, settings, vector, fetch, nil)
+		if err != nil {
+			return nil, err
+		}
+		return finish(results, config, fetch, limit), nil
+	}
+
+	// Vector results are filtered before the thresholds, so that the adaptive
+	// cut is computed on results that can be returned. Keyword results are
+	// filtered once ranked, so fetch more until enough of them pass or
+	// fetching more finds nothing new.
+	var results []db.SearchResult
+	fetched := -1
+	for n := fetch * filterOverFetch; ; n = min(n*filterOverFetch, db.MaxVectorResults) {
+		ranked, err := s.rank(mode, config, settings, vector, n, filter)
+		if err != nil {
+			return nil, err
+		}
+		results = filter.apply(ranked)
+		if len(results) >= fetch || len(ranked) <= fetched || n >= db.MaxVectorResults {
+			break
+		}
+		fetched = len(ranked)
+	}
+	return finish(results, config, fetch, limit), nil
+}
+
+// rank returns up to fetch results of the query of config in mode. The query
+// embedding, nil in keyword mode, is computed once per search. filter, if
+// not nil, is applied to vector results before their thresholds.
+func (s *Searcher) rank(mode string, config *Config, settings models.SearchDefaults, vector []float32, fetch int, filter *resultFilter) ([]db.SearchResult, error) {
+	switch mode {
+	case ModeKeyword:
+		return db.SearchKeyword(s.db, config.Query, fetch)
+	case ModeHybrid:
+		return s.hybridSearch(config, settings, vector, fetch, filter)
+	}
+	return s.vectorSearch(settings, vector, fetch, filter)
+}
+
+// finish cuts results to fetch chunks and, for file level searches,
+// aggregates them into limit files.
+func finish(results []db.SearchResult, config *Config, fetch, limit int) []db.SearchResult {
+	if len(results) > fetch {
+		results = results[:fetch]
+	}
+	if config.FileLevel {
+		results = db.AggregateByFile(results)
+		if len(results) > limit {
+			results = results[:limit]
+		}
+	}
+	return results
+}
+
+// ResolveMode validates mode and returns the mode a search with it runs in,
+// vector search when it is empty. Hybrid search falls back to vector search
+// when keyword search is not available in this build, see FallbackWarning.
+func ResolveMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return ModeVector, nil
+	case ModeHybrid:
+		if !db.KeywordSearchAvailable() {
+			return ModeVector, nil
+		}
+		return ModeHybrid, nil
+	case ModeVector, ModeKeyword:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown search mode '%s', expected one of: %s", mode, strings.Join(Modes, ", "))
+}
+
+// FallbackWarning returns a warning when a search requested in mode runs in
+// another mode, empty otherwise.
+func FallbackWarning(mode string) string {
+	if mode != ModeHybrid || db.KeywordSearchAvailable() {
+		return ""
+	}
+	return "keyword search is not available in this build, hybrid search falls back to vector search. Build with: go build -tags sqlite_fts5"
+}
+
+// KeywordIndexWarning returns a warning when a search of p in mode relies on
+// a keyword index that does not hold every chunk, empty otherwise.
+func KeywordIndexWarning(p *models.Project, mode string) string {
+	if mode == ModeVector || p.KeywordIndex || !db.KeywordSearchAvailable() {
+		return ""
+	}
+	return fmt.Sprintf("the keyword index of project '%s' is incomplete, keyword matches may be missing. Rebuild it with: codesearch sync %s", p.Alias, p.Alias)
+}
+
+// queryInput returns the text embedded for query, formatted with the query
+// template of the project.
+func (s *Searcher) queryInput(query string) string {
+	return project.RenderQuery(s.project.Templates.Query, query)
+}
+
+// embedQuery returns the embeddings of query and of client.ProbeText. The
+// probe is embedded in the same request to detect a changed model.
+func (s *Searcher) embedQuery(ctx context.Context, query string) ([]float32, models.Embedding, error) {
+	vectors, err := s.embedder.Embed(ctx, []string{query, client.ProbeText})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating embeddings for query: %w", err)
+	}
+	return vectors[0].Float32(), vectors[1], nil
+}
+
+// checkModel verifies that the probe embedding matches the vectors the
+// project was indexed with.
+func (s *Searcher) checkModel(probe models.Embedding) error {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+	return project.CheckModel(s.db, s.project, client.NewIdentity(s.project.Client, s.project.Model, probe))
+}
+
+// vectorSearch returns the chunks most similar to the query embedding that
+// pass filter, if not nil, and the thresholds of settings.
+func (s *Searcher) vectorSearch(settings models.SearchDefaults, vector []float32, fetch int, filter *resultFilter) ([]db.SearchResult, error) {
+	opts := searchOptions(settings, fetch)
+	if filter != nil {
+		opts.Keep = filter.keep
+	}
+	results, err := db.SearchSimilar(s.db, vector, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error searching for similar files: %w", err)
+	}
 	return results, nil
 }
+
+// searchOptions converts resolved settings to vector search options.
+func searchOptions(settings models.SearchDefaults, fetch int) db.SearchOptions {
+	maxDistance := 1 - *settings.MinSimilarity
+	if settings.MaxDistance != nil && *settings.MaxDistance < maxDistance {
+		maxDistance = *settings.MaxDistance
+	}
+	return db.SearchOptions{
+		MaxDistance: maxDistance,
+		MinResults:  *settings.MinResults,
+		MaxResults:  fetch,
+		UseAdaptive: *settings.Adaptive,
+	}
+}
+
+// hybridSearch fuses the vector and keyword rankings of the query.
+func (s *Searcher) hybridSearch(config *Config, settings models.SearchDefaults, vector []float32, fetch int, filter *resultFilter) ([]db.SearchResult, error) {
+	vectorResults, err := s.vectorSearch(settings, vector, fetch*hybridOverFetch, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	keyword, err := db.SearchKeyword(s.db, config.Query, fetch*hybridOverFetch)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.FuseRankings(vectorResults, keyword, config.VectorWeight), nil
+}
+
+// ValidateDefaults reports search defaults outside of their valid ranges.
+func ValidateDefaults(d models.SearchDefaults) error {
+	if d.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", d.Limit)
+	}
+	if d.MinSimilarity != nil && (*d.MinSimilarity < -1 || *d.MinSimilarity > 1) {
+		return fmt.Errorf("minimum similarity must be between -1 and 1, got %g", *d.MinSimilarity)
+	}
+	if d.MaxDistance != nil && (*d.MaxDistance < 0 || *d.MaxDistance > 2) {
+		return fmt.Errorf("maximum distance must be between 0 and 2, got %g", *d.MaxDistance)
+	}
+	if d.MinResults != nil && *d.MinResults < 0 {
+		return fmt.Errorf("minimum results must not be negative, got %d", *d.MinResults)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package search
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	codesync "github.com/andrejsstepanovs/codesearch/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchDefaultsPrecedence(t *testing.T) {
+	projectSimilarity, flagDistance := 0.4, 0.3
+	config := models.SearchDefaults{MaxDistance: &flagDistance}
+	stored := models.SearchDefaults{Limit: 25, MinSimilarity: &projectSimilarity}
+
+	settings := config.Or(stored).Or(builtinDefaults)
+	assert.Equal(t, 25, settings.Limit)
+
+	opts := searchOptions(settings, 50)
+	assert.InDelta(t, 0.3, opts.MaxDistance, 1e-9, "the stricter threshold applies")
+	assert.Equal(t, DefaultMinResults, opts.MinResults)
+	assert.True(t, opts.UseAdaptive)
+	assert.Equal(t, 50, opts.MaxResults)
+
+	opts = searchOptions(models.SearchDefaults{}.Or(builtinDefaults), 10)
+	assert.InDelta(t, 1-DefaultMinSimilarity, opts.MaxDistance, 1e-9)
+}
+
+func TestValidateDefaults(t *testing.T) {
+	assert.NoError(t, ValidateDefaults(models.SearchDefaults{}))
+	assert.Error(t, ValidateDefaults(models.SearchDefaults{Limit: -1}))
+	assert.Error(t, ValidateDefaults(models.SearchDefaults{MinSimilarity: ptr(1.5)}))
+	assert.Error(t, ValidateDefaults(models.SearchDefaults{MaxDistance: ptr(-0.1)}))
+	assert.Error(t, ValidateDefaults(models.SearchDefaults{MinResults: ptr(-2)}))
+	assert.NoError(t, ValidateDefaults(models.SearchDefaults{Limit: 5, MinSimilarity: ptr(0.5), MaxDistance: ptr(0.5), MinResults: ptr(0), Adaptive: ptr(false)}))
+}
+
+func TestSearchTemplates(t *testing.T) {
+	root := t.TempDir()
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "templates"), 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+	_, err = db.SaveFileChunks(dbConn, models.File{File: "/a.go"}, []models.Chunk{{StartLine: 1, EndLine: 2}}, []models.Embedding{{1, 0}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.go"), []byte("package b\nfunc B() {}\n"), 0644))
+
+	embedder := &fixedEmbedder{vector: models.Embedding{1, 0}}
+	templates := models.Templates{Document: "search_document: {path}\n{content}", Query: "search_query: {query}"}
+	s := &Searcher{db: dbConn, project: &models.Project{Path: root, Templates: templates}, embedder: embedder}
+	ctx := context.Background()
+
+	_, err = s.Search(ctx, &Config{Query: "parse config", Mode: ModeVector})
+	require.NoError(t, err)
+	assert.Equal(t, "search_query: parse config", embedder.inputs[0])
+
+	// Code embedded for similar is formatted like an indexed chunk.
+	_, err = s.Similar(ctx, Target{Path: "b.go", StartLine: 2}, &Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "search_document: /b.go\nfunc B() {}", embedder.inputs[2])
+}
+
+// TestOfflinePipeline builds, syncs and searches a project with the hash
+// client, which needs no embedding server.
+func TestOfflinePipeline(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+	root := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+	write("config.go", "package app\n\n// parseConfig reads the configuration file.\nfunc parseConfig(path string) (*Config, error) {\n\treturn nil, nil\n}\n")
+	write("server.go", "package app\n\n// startServer listens for HTTP requests.\nfunc startServer(addr string) error {\n\treturn nil\n}\n")
+
+	ctx := context.Background()
+	build := &codesync.Config{
+		ProjectAlias: "offline",
+		ProjectPath:  root,
+		ClientName:   "hash",
+		ModelName:    "hash",
+		Extensions:   []string{"go"},
+		BatchSize:    codesync.DefaultBatchSize,
+		BatchBytes:   codesync.DefaultBatchBytes,
+		Workers:      codesync.DefaultWorkers,
+		Output:       io.Discard,
+	}
+	require.NoError(t, codesync.Run(ctx, build))
+
+	adaptive := false
+	find := func(query string) []db.SearchResult {
+		results, err := Run(ctx, &Config{ProjectAlias: "offline", Query: query, Mode: ModeVector, SearchDefaults: models.SearchDefaults{Adaptive: &adaptive}})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		return results
+	}
+	assert.Equal(t, "/config.go", find("parse config")[0].File)
+	assert.Equal(t, "/server.go", find("start the http server")[0].File)
+
+	write("cache.go", "package app\n\n// evictCache drops expired cache entries.\nfunc evictCache() {}\n")
+	require.NoError(t, codesync.RunSync(ctx, &codesync.Config{ProjectAlias: "offline", Output: io.Discard}))
+	assert.Equal(t, "/cache.go", find("evict expired cache entries")[0].File)
+}
+
+func TestKeywordIndexWarning(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "warning"), 2)
+	require.NoError(t, err)
+	dbConn.Close()
+
+	incomplete := &models.Project{Alias: "old"}
+	assert.Empty(t, KeywordIndexWarning(incomplete, ModeVector))
+	assert.Empty(t, KeywordIndexWarning(&models.Project{Alias: "new", KeywordIndex: true}, ModeHybrid))
+	if db.KeywordSearchAvailable() {
+		assert.Contains(t, KeywordIndexWarning(incomplete, ModeKeyword), "codesearch sync old")
+	}
+}
+
+func TestResolveMode(t *testing.T) {
+	mode, err := ResolveMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ModeVector, mode, "vector search is the default")
+	assert.Empty(t, FallbackWarning(ModeVector))
+
+	mode, err = ResolveMode(ModeHybrid)
+	require.NoError(t, err)
+	if db.KeywordSearchAvailable() {
+		assert.Equal(t, ModeHybrid, mode)
+		assert.Empty(t, FallbackWarning(ModeHybrid))
+	} else {
+		assert.Equal(t, ModeVector, mode)
+		assert.Contains(t, FallbackWarning(ModeHybrid), "sqlite_fts5")
+	}
+
+	_, err = ResolveMode("fuzzy")
+	assert.ErrorContains(t, err, "unknown search mode 'fuzzy'")
+}
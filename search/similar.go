@@ -0,0 +1,202 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/project"
+)
+
+// Target is the code to find similar code for: a file of the project, or a
+// range of its lines.
+type Target struct {
+	// Path is relative to the project root, or absolute inside of it.
+	Path string
+	// StartLine and EndLine are 1-based and inclusive, zero for the whole file.
+	StartLine int
+	EndLine   int
+}
+
+var targetRangePattern = regexp.MustCompile(`^(.+):(\d+)(?:-(\d+))?$`)
+
+// ParseTarget parses "path", "path:line" or "path:start-end".
+func ParseTarget(arg string) (Target, error) {
+	m := targetRangePattern.FindStringSubmatch(arg)
+	if m == nil {
+		if arg == "" {
+			return Target{}, fmt.Errorf("path cannot be empty")
+		}
+		return Target{Path: arg}, nil
+	}
+
+	target := Target{Path: m[1]}
+	target.StartLine, _ = strconv.Atoi(m[2])
+	target.EndLine = target.StartLine
+	if m[3] != "" {
+		target.EndLine, _ = strconv.Atoi(m[3])
+	}
+	if target.StartLine < 1 || target.EndLine < target.StartLine {
+		return Target{}, fmt.Errorf("invalid line range %s:%s", m[2], m[3])
+	}
+	return target, nil
+}
+
+// String formats the target as accepted by ParseTarget.
+func (t Target) String() string {
+	if t.StartLine == 0 {
+		return t.Path
+	}
+	if t.EndLine == t.StartLine {
+		return fmt.Sprintf("%s:%d", t.Path, t.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", t.Path, t.StartLine, t.EndLine)
+}
+
+// Similar returns the chunks most similar to target, leaving out the target
+// file itself. The stored vectors are reused for an indexed file and for a
+// range matching one of its chunks; other ranges and files that are not
+// indexed are read from the project sources and embedded. The query, mode
+// and vector weight of config are ignored.
+func (s *Searcher) Similar(ctx context.Context, target Target, config *Config) ([]db.SearchResult, error) {
+	if err := ValidateDefaults(config.SearchDefaults); err != nil {
+		return nil, err
+	}
+
+	path, err := s.indexPath(target.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	vector, err := s.storedVector(path, target)
+	if err != nil {
+		return nil, err
+	}
+	if vector == nil {
+		vector, err = s.embedTarget(ctx, path, target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := *config
+	c.skipFile = path
+	return s.search(&c, ModeVector, vector)
+}
+
+// indexPath converts a path given on the command line to the form files are
+// stored in the index, relative to the project root with a leading slash.
+func (s *Searcher) indexPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(s.project.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("path %s is outside of the project %s", path, s.project.Path)
+		}
+		path = rel
+	}
+	return "/" + strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/"), nil
+}
+
+// storedVector returns the vector of target from the index, or nil when it
+// has to be embedded. A whole file is represented by the mean of its chunk
+// vectors.
+func (s *Searcher) storedVector(path string, target Target) ([]float32, error) {
+	file, err := db.GetFileByPath(s.db, path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := db.GetFileChunks(s.db, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := db.GetChunkVectors(s.db, file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.StartLine == 0 {
+		var all [][]float32
+		for _, chunk := range chunks {
+			if v, ok := vectors[chunk.ID]; ok {
+				all = append(all, v)
+			}
+		}
+		return meanVector(all), nil
+	}
+
+	for _, chunk := range chunks {
+		if chunk.StartLine == target.StartLine && chunk.EndLine == target.EndLine {
+			return vectors[chunk.ID], nil
+		}
+	}
+	return nil, nil
+}
+
+// embedTarget reads target from the project sources and embeds it like an
+// indexed chunk, formatted with the document template of the project.
+func (s *Searcher) embedTarget(ctx context.Context, path string, target Target) ([]float32, error) {
+	lines, err := project.ReadLines(s.project.Path, path, target.StartLine, target.EndLine)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	if text == "" {
+		return nil, fmt.Errorf("%s is empty", target)
+	}
+
+	input := project.RenderDocument(s.project.Templates.Document, path, "", text)
+	vector, probe, err := s.embedQuery(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkModel(probe); err != nil {
+		return nil, err
+	}
+	return vector, nil
+}
+
+// meanVector averages vectors, scaled to their mean length so that it is
+// comparable to the stored vectors. It returns nil for no vectors.
+func meanVector(vectors [][]float32) []float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	mean := make([]float64, len(vectors[0]))
+	var length float64
+	for _, v := range vectors {
+		var sum float64
+		for i, x := range v {
+			mean[i] += float64(x)
+			sum += float64(x) * float64(x)
+		}
+		length += math.Sqrt(sum)
+	}
+	length /= float64(len(vectors))
+
+	var norm float64
+	for _, x := range mean {
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+
+	result := make([]float32, len(mean))
+	if norm == 0 {
+		return result
+	}
+	for i, x := range mean {
+		result[i] = float32(x / norm * length)
+	}
+	return result
+}
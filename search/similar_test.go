@@ -0,0 +1,112 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedEmbedder embeds every input as the same vector.
+type fixedEmbedder struct {
+	vector models.Embedding
+	inputs []string
+}
+
+func (e *fixedEmbedder) Embed(ctx context.Context, inputs []string) ([]models.Embedding, error) {
+	e.inputs = append(e.inputs, inputs...)
+	out := make([]models.Embedding, len(inputs))
+	for i := range out {
+		out[i] = e.vector
+	}
+	return out, nil
+}
+
+func (e *fixedEmbedder) Dimensions(ctx context.Context) (int, error) { return len(e.vector), nil }
+func (e *fixedEmbedder) Name() string                                { return "fixed" }
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("internal/auth.go")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Path: "internal/auth.go"}, target)
+
+	target, err = ParseTarget("/internal/auth.go:12-30")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Path: "/internal/auth.go", StartLine: 12, EndLine: 30}, target)
+	assert.Equal(t, "/internal/auth.go:12-30", target.String())
+
+	target, err = ParseTarget("auth.go:7")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Path: "auth.go", StartLine: 7, EndLine: 7}, target)
+	assert.Equal(t, "auth.go:7", target.String())
+
+	_, err = ParseTarget("auth.go:30-12")
+	assert.Error(t, err)
+	_, err = ParseTarget("")
+	assert.Error(t, err)
+}
+
+func TestMeanVector(t *testing.T) {
+	assert.Nil(t, meanVector(nil))
+	mean := meanVector([][]float32{{1, 0}, {0, 1}})
+	assert.InDelta(t, 0.7071, mean[0], 1e-4)
+	assert.InDelta(t, 0.7071, mean[1], 1e-4)
+}
+
+func TestSimilar(t *testing.T) {
+	root := t.TempDir()
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "similar"), 2)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	saveVectors := func(file string, chunks []models.Chunk, vectors []models.Embedding) {
+		_, err := db.SaveFileChunks(dbConn, models.File{File: file}, chunks, vectors)
+		require.NoError(t, err)
+	}
+	saveVectors("/a.go", []models.Chunk{{StartLine: 1, EndLine: 5}, {StartLine: 6, EndLine: 9}}, []models.Embedding{{1, 0}, {0, 1}})
+	saveVectors("/b.go", []models.Chunk{{StartLine: 1, EndLine: 3}}, []models.Embedding{{0.7, 0.7}})
+	saveVectors("/c.go", []models.Chunk{{StartLine: 1, EndLine: 3}}, []models.Embedding{{0, 1}})
+
+	embedder := &fixedEmbedder{vector: models.Embedding{1, 0}}
+	s := &Searcher{db: dbConn, project: &models.Project{Path: root}, embedder: embedder}
+	ctx := context.Background()
+	config := &Config{}
+
+	// The whole file is the mean of its chunks, closest to b.go.
+	results, err := s.Similar(ctx, Target{Path: "a.go"}, config)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "/b.go", results[0].File)
+	for _, r := range results {
+		assert.NotEqual(t, "/a.go", r.File, "the target file is left out")
+	}
+
+	// A range matching a chunk reuses its vector.
+	results, err = s.Similar(ctx, Target{Path: filepath.Join(root, "a.go"), StartLine: 6, EndLine: 9}, config)
+	require.NoError(t, err)
+	assert.Equal(t, "/c.go", results[0].File)
+
+	// The chunk itself is left out before the adaptive threshold, which would
+	// otherwise cut at the gap behind it and keep nothing else.
+	results, err = s.Similar(ctx, Target{Path: "a.go", StartLine: 1, EndLine: 5}, config)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "/b.go", results[0].File)
+	assert.Empty(t, embedder.inputs, "stored vectors need no embedding call")
+
+	// Other ranges are read from the sources and embedded.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.go"), []byte("package a\nfunc A() {}\n"), 0644))
+	results, err = s.Similar(ctx, Target{Path: "a.go", StartLine: 2, EndLine: 2}, config)
+	require.NoError(t, err)
+	assert.Equal(t, "/a.go\nfunc A() {}", embedder.inputs[0], "embedded like an indexed chunk")
+	require.NotEmpty(t, results)
+	assert.NotEqual(t, "/a.go", results[0].File)
+
+	_, err = s.Similar(ctx, Target{Path: "/elsewhere/x.go"}, config)
+	assert.ErrorContains(t, err, "outside of the project")
+}
@@ -0,0 +1,401 @@
+// Package server exposes project indexes over an HTTP JSON API.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/project"
+	"github.com/andrejsstepanovs/codesearch/search"
+	codesync "github.com/andrejsstepanovs/codesearch/sync"
+)
+
+// DefaultAddr is the default listen address.
+const DefaultAddr = "127.0.0.1:8080"
+
+// shutdownTimeout bounds how long running requests may take to finish on shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// Server answers API requests. Project databases are opened on first use and
+// kept open until the server shuts down, or until the project changes through
+// another process, such as a build or remove on the command line.
+type Server struct {
+	clientOptions client.Options
+
+	mu        sync.Mutex
+	searchers map[string]*openSearcher
+	syncing   map[string]bool
+}
+
+// openSearcher is a cached searcher with the number of requests using it. A
+// retired searcher is closed once the last of them is done.
+type openSearcher struct {
+	*search.Searcher
+	users   int
+	retired bool
+}
+
+// New returns a server reaching embedding providers with clientOptions.
+func New(clientOptions client.Options) *Server {
+	return &Server{
+		clientOptions: clientOptions,
+		searchers:     make(map[string]*openSearcher),
+		syncing:       make(map[string]bool),
+	}
+}
+
+// Handler returns the HTTP handler of the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /projects", s.handleProjects)
+	mux.HandleFunc("GET /projects/{alias}", s.handleProject)
+	mux.HandleFunc("GET /projects/{alias}/search", s.handleSearch)
+	mux.HandleFunc("POST /projects/{alias}/sync", s.handleSync)
+	return mux
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled, then waits
+// for running requests to finish and closes all project databases. Requests
+// still running after shutdownTimeout are cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return requestCtx },
+	}
+	defer s.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		cancelRequests()
+		return fmt.Errorf("error shutting down: %w", err)
+	}
+	return nil
+}
+
+// Close closes all open project databases.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for alias := range s.searchers {
+		s.retire(alias)
+	}
+}
+
+// searcher returns the open searcher of alias, opening it on first use and
+// again when the project changed since. release must be called when the
+// request is done with it.
+func (s *Server) searcher(alias string) (searcher *search.Searcher, release func(), err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open, ok := s.searchers[alias]
+	if ok {
+		stale, err := open.Stale()
+		if err != nil {
+			return nil, nil, err
+		}
+		if stale {
+			s.retire(alias)
+			ok = false
+		}
+	}
+	if !ok {
+		searcher, err := search.Open(alias, s.clientOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+		open = &openSearcher{Searcher: searcher}
+		s.searchers[alias] = open
+	}
+
+	open.users++
+	release = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		open.users--
+		if open.retired && open.users == 0 {
+			closeSearcher(alias, open)
+		}
+	}
+	return open.Searcher, release, nil
+}
+
+// retire removes the cached searcher of alias, so that the next request opens
+// it again. s.mu must be held.
+func (s *Server) retire(alias string) {
+	open, ok := s.searchers[alias]
+	if !ok {
+		return
+	}
+	delete(s.searchers, alias)
+	open.retired = true
+	if open.users == 0 {
+		closeSearcher(alias, open)
+	}
+}
+
+func closeSearcher(alias string, open *openSearcher) {
+	if err := open.Close(); err != nil {
+		log.Printf("Error closing database of project %s: %v", alias, err)
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// projectJSON is the API representation of a project.
+type projectJSON struct {
+	Alias        string     `json:"alias"`
+	Path         string     `json:"path"`
+	Client       string     `json:"client,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	Extensions   []string   `json:"extensions,omitempty"`
+	Files        int        `json:"files"`
+	Chunks       int        `json:"chunks"`
+	DatabaseSize int64      `json:"database_size"`
+	SyncedAt     *time.Time `json:"synced_at,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+func newProjectJSON(s project.Summary) projectJSON {
+	p := projectJSON{
+		Alias:        s.Alias,
+		Path:         s.Path,
+		Client:       s.Client,
+		Model:        s.Model,
+		Extensions:   s.Extensions,
+		Files:        s.Stats.Files,
+		Chunks:       s.Stats.Chunks,
+		DatabaseSize: s.DatabaseSize,
+	}
+	if !s.SyncedAt.IsZero() {
+		p.SyncedAt = &s.SyncedAt
+	}
+	if s.Err != nil {
+		p.Error = s.Err.Error()
+	}
+	return p
+}
+
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	summaries, err := project.List()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	projects := make([]projectJSON, 0, len(summaries))
+	for _, summary := range summaries {
+		projects = append(projects, newProjectJSON(summary))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"projects": projects})
+}
+
+func (s *Server) handleProject(w http.ResponseWriter, r *http.Request) {
+	summary, err := project.Info(r.PathValue("alias"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newProjectJSON(summary))
+}
+
+// handleSearch takes the query in q and the optional limit, mode,
+// vector_weight, files, path, ext and exclude parameters, named like the find
+// flags. Filter parameters may be repeated; ext may also be comma separated.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	params := r.URL.Query()
+
+	config, err := search.ParseConfig([]string{alias, params.Get("q")})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorJSON{err.Error()})
+		return
+	}
+	if v := params.Get("mode"); v != "" {
+		config.Mode = v
+	}
+	if v := params.Get("limit"); v != "" {
+		config.Limit, err = strconv.Atoi(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorJSON{fmt.Sprintf("invalid limit: %s", v)})
+			return
+		}
+	}
+	if v := params.Get("vector_weight"); v != "" {
+		config.VectorWeight, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorJSON{fmt.Sprintf("invalid vector_weight: %s", v)})
+			return
+		}
+	}
+	if v := params.Get("files"); v != "" {
+		config.FileLevel, err = strconv.ParseBool(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorJSON{fmt.Sprintf("invalid files: %s", v)})
+			return
+		}
+	}
+
+	if err := search.ValidateDefaults(config.SearchDefaults); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorJSON{err.Error()})
+		return
+	}
+	config.Paths = patternParam(params["path"])
+	config.Extensions = listParam(params["ext"])
+	config.Excludes = patternParam(params["exclude"])
+
+	searcher, release, err := s.searcher(alias)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+
+	results, err := searcher.Search(r.Context(), config)
+	if err != nil {
+		if errors.Is(err, client.ErrModelMismatch) {
+			s.mu.Lock()
+			s.retire(alias)
+			s.mu.Unlock()
+		}
+		writeError(w, err)
+		return
+	}
+	if results == nil {
+		results = []db.SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"query": config.Query, "results": results})
+}
+
+// listParam splits repeated, comma separated parameter values.
+func listParam(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// patternParam returns the non-empty values of a repeated pattern parameter.
+// Patterns are not split at commas, which they may contain, e.g. '*.{go,ts}'.
+func patternParam(values []string) []string {
+	var list []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// handleSync runs a sync of the project and returns its report. Only one
+// sync per project runs at a time.
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+
+	s.mu.Lock()
+	if s.syncing[alias] {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusConflict, errorJSON{fmt.Sprintf("project '%s' is already syncing", alias)})
+		return
+	}
+	s.syncing[alias] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.syncing, alias)
+		s.mu.Unlock()
+	}()
+
+	var out bytes.Buffer
+	config := &codesync.Config{
+		ProjectAlias:  alias,
+		ClientOptions: s.clientOptions,
+		BatchSize:     codesync.DefaultBatchSize,
+		BatchBytes:    codesync.DefaultBatchBytes,
+		Workers:       codesync.DefaultWorkers,
+		Output:        &out,
+		Progress:      io.Discard,
+	}
+	err := codesync.RunSync(r.Context(), config)
+
+	// The sync may have recorded a new model identity or keyword index state.
+	s.mu.Lock()
+	s.retire(alias)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	report := strings.Split(strings.TrimSpace(out.String()), "\n")
+	writeJSON(w, http.StatusOK, map[string]any{"project": alias, "report": report})
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+// writeError maps err to a status code and writes it.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, db.ErrProjectNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, client.ErrModelMismatch):
+		status = http.StatusConflict
+	case errors.Is(err, context.Canceled):
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, errorJSON{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
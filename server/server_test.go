@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, h http.Handler, method, url string) (int, map[string]any) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestHandler(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+
+	dbConn, err := db.SetupDatabase("demo", 2)
+	require.NoError(t, err)
+	require.NoError(t, db.UpsertProject(dbConn, models.Project{Alias: "demo", Path: "/src/demo", Client: "ollama", Model: "m"}))
+	_, err = db.SaveFileChunks(dbConn, models.File{File: "/main.go"}, []models.Chunk{{Symbol: "handleBuild", StartLine: 3, EndLine: 5, Content: "func handleBuild() {}"}}, []models.Embedding{{1, 0}})
+	require.NoError(t, err)
+	require.NoError(t, dbConn.Close())
+	require.NoError(t, db.RegisterProject("demo", "/src/demo"))
+
+	s := New(client.Options{})
+	defer s.Close()
+	h := s.Handler()
+
+	code, body := get(t, h, http.MethodGet, "/health")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body["status"])
+
+	code, body = get(t, h, http.MethodGet, "/projects")
+	assert.Equal(t, http.StatusOK, code)
+	projects := body["projects"].([]any)
+	require.Len(t, projects, 1)
+	assert.Equal(t, "demo", projects[0].(map[string]any)["alias"])
+	assert.Equal(t, float64(1), projects[0].(map[string]any)["files"])
+
+	code, _ = get(t, h, http.MethodGet, "/projects/missing")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = get(t, h, http.MethodGet, "/projects/missing/search?q=x&mode=keyword")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body["error"], "project not found")
+
+	code, _ = get(t, h, http.MethodGet, "/projects/demo/search")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = get(t, h, http.MethodGet, "/projects/demo/search?q=x&limit=ten")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = get(t, h, http.MethodPost, "/projects/missing/sync")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	if !db.KeywordSearchAvailable() {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+	code, body = get(t, h, http.MethodGet, "/projects/demo/search?q=handleBuild&mode=keyword")
+	require.Equal(t, http.StatusOK, code, body)
+	results := body["results"].([]any)
+	require.Len(t, results, 1)
+	result := results[0].(map[string]any)
+	assert.Equal(t, "/main.go", result["file"])
+	assert.Equal(t, "handleBuild", result["symbol"])
+	assert.Equal(t, float64(3), result["start_line"])
+}
+
+func TestSearcherReload(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+	setup := func(model string) {
+		dbConn, err := db.SetupDatabase("demo", 2)
+		require.NoError(t, err)
+		defer dbConn.Close()
+		require.NoError(t, db.UpsertProject(dbConn, models.Project{Alias: "demo", Path: "/src/demo", Client: "hash", Model: model}))
+		require.NoError(t, db.MarkProjectSynced(dbConn, "demo", time.Now()))
+		require.NoError(t, db.RegisterProject("demo", "/src/demo"))
+	}
+	setup("first")
+
+	s := New(client.Options{})
+	defer s.Close()
+	model := func() string {
+		searcher, release, err := s.searcher("demo")
+		require.NoError(t, err)
+		defer release()
+		return searcher.Project().Model
+	}
+	assert.Equal(t, "first", model())
+	cached := s.searchers["demo"]
+	assert.Equal(t, "first", model())
+	assert.Same(t, cached, s.searchers["demo"], "unchanged projects stay open")
+
+	// A build from the command line changes the model.
+	setup("second")
+	assert.Equal(t, "second", model())
+
+	// So does a remove followed by a new build, into a new database file.
+	require.NoError(t, db.RemoveProject("demo"))
+	_, _, err := s.searcher("demo")
+	assert.ErrorIs(t, err, db.ErrProjectNotFound)
+	setup("third")
+	assert.Equal(t, "third", model())
+}
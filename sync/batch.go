@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"context"
+	"log"
+
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/models"
+)
+
+const (
+	// DefaultBatchSize is the default number of inputs sent per embedding request.
+	DefaultBatchSize = 32
+	// DefaultBatchBytes is the default input byte budget per embedding request.
+	DefaultBatchBytes = 256 * 1024
+)
+
+// pendingFile is a file read from disk that needs new embeddings.
+type pendingFile struct {
+	path   string         // absolute path, used for logging
+	meta   models.File    // metadata to store
+	chunks []models.Chunk // chunks to store
+	inputs []string       // text sent to the embedding model, one per chunk
+	fileID int64          // existing record to replace, 0 for new files
+
+	split     bool // chunks were split to fit the model input budget
+	truncated bool // content was truncated to fit the model input budget
+
+	skipped string // reason the file is not indexed, see file.Filter
+}
+
+func (p pendingFile) size() int {
+	n := 0
+	for _, input := range p.inputs {
+		n += len(input)
+	}
+	return n
+}
+
+// embeddedFile is a pendingFile together with the outcome of embedding it.
+type embeddedFile struct {
+	pendingFile
+	embeddings []models.Embedding
+	err        error
+}
+
+// batchLimits bound a single embedding request.
+type batchLimits struct {
+	maxItems int
+	maxBytes int
+}
+
+func newBatchLimits(maxItems, maxBytes int) batchLimits {
+	if maxItems <= 0 {
+		maxItems = DefaultBatchSize
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultBatchBytes
+	}
+	return batchLimits{maxItems: maxItems, maxBytes: maxBytes}
+}
+
+// batcher groups pending files into batches limited by input count and by
+// total input bytes.
+type batcher struct {
+	limits batchLimits
+	items  []pendingFile
+	inputs int
+	bytes  int
+}
+
+func newBatcher(limits batchLimits) *batcher {
+	return &batcher{limits: limits}
+}
+
+// add appends item and returns a batch that is ready to be embedded, if any.
+// An item larger than the limits is sent alone.
+func (b *batcher) add(item pendingFile) []pendingFile {
+	var ready []pendingFile
+	size := item.size()
+	if len(b.items) > 0 && (b.bytes+size > b.limits.maxBytes || b.inputs+len(item.inputs) > b.limits.maxItems) {
+		ready = b.flush()
+	}
+
+	b.items = append(b.items, item)
+	b.inputs += len(item.inputs)
+	b.bytes += size
+
+	if ready == nil && b.inputs >= b.limits.maxItems {
+		ready = b.flush()
+	}
+	return ready
+}
+
+// flush returns the buffered items and resets the batcher.
+func (b *batcher) flush() []pendingFile {
+	if len(b.items) == 0 {
+		return nil
+	}
+	items := b.items
+	b.items = nil
+	b.inputs = 0
+	b.bytes = 0
+	return items
+}
+
+// embedBatch embeds the inputs of all items. When that fails the items are
+// retried one by one, so a single bad input does not cost the whole batch.
+func embedBatch(ctx context.Context, embedder client.Embedder, limits batchLimits, items []pendingFile) []embeddedFile {
+	var inputs []string
+	for _, item := range items {
+		inputs = append(inputs, item.inputs...)
+	}
+
+	results := make([]embeddedFile, len(items))
+	vectors, err := embedInputs(ctx, embedder, limits, inputs)
+	if err == nil {
+		offset := 0
+		for i, item := range items {
+			results[i] = embeddedFile{pendingFile: item, embeddings: vectors[offset : offset+len(item.inputs)]}
+			offset += len(item.inputs)
+		}
+		return results
+	}
+
+	if len(items) == 1 || ctx.Err() != nil {
+		for i, item := range items {
+			results[i] = embeddedFile{pendingFile: item, err: err}
+		}
+		return results
+	}
+
+	log.Printf("Batch of %d files failed, retrying individually: %v", len(items), err)
+	for i, item := range items {
+		results[i] = embedBatch(ctx, embedder, limits, []pendingFile{item})[0]
+	}
+	return results
+}
+
+// embedInputs embeds inputs using as many requests as the limits require.
+func embedInputs(ctx context.Context, embedder client.Embedder, limits batchLimits, inputs []string) ([]models.Embedding, error) {
+	vectors := make([]models.Embedding, 0, len(inputs))
+	for start := 0; start < len(inputs); {
+		end, size := start, 0
+		for end < len(inputs) && end-start < limits.maxItems {
+			if end > start && size+len(inputs[end]) > limits.maxBytes {
+				break
+			}
+			size += len(inputs[end])
+			end++
+		}
+
+		res, err := embedder.Embed(ctx, inputs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, res...)
+		start = end
+	}
+	return vectors, nil
+}
@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	gosync "sync"
+	"testing"
+
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pending(inputs ...string) pendingFile {
+	return pendingFile{path: inputs[0], inputs: inputs}
+}
+
+func inputs(items []pendingFile) []string {
+	var res []string
+	for _, item := range items {
+		res = append(res, item.inputs...)
+	}
+	return res
+}
+
+func TestBatcher(t *testing.T) {
+	t.Run("limits item count", func(t *testing.T) {
+		b := newBatcher(newBatchLimits(2, 1000))
+		assert.Nil(t, b.add(pending("a")))
+		assert.Equal(t, []string{"a", "b"}, inputs(b.add(pending("b"))))
+		assert.Nil(t, b.add(pending("c")))
+		assert.Equal(t, []string{"c"}, inputs(b.flush()))
+		assert.Nil(t, b.flush())
+	})
+
+	t.Run("limits bytes", func(t *testing.T) {
+		b := newBatcher(newBatchLimits(10, 5))
+		assert.Nil(t, b.add(pending("aaa")))
+		assert.Equal(t, []string{"aaa"}, inputs(b.add(pending("bbb"))))
+		assert.Equal(t, []string{"bbb"}, inputs(b.flush()))
+	})
+
+	t.Run("oversized item is sent alone", func(t *testing.T) {
+		b := newBatcher(newBatchLimits(10, 5))
+		assert.Nil(t, b.add(pending("a")))
+		assert.Equal(t, []string{"a"}, inputs(b.add(pending("bbbbbbbbbb"))))
+		assert.Equal(t, []string{"bbbbbbbbbb"}, inputs(b.add(pending("c"))))
+		assert.Equal(t, []string{"c"}, inputs(b.flush()))
+	})
+
+	t.Run("counts chunk inputs", func(t *testing.T) {
+		b := newBatcher(newBatchLimits(3, 1000))
+		assert.Nil(t, b.add(pending("a", "b")))
+		assert.Equal(t, []string{"a", "b"}, inputs(b.add(pending("c", "d"))))
+		assert.Equal(t, []string{"c", "d"}, inputs(b.flush()))
+	})
+}
+
+// fakeEmbedder returns the input length as a one dimensional vector and
+// fails every request that contains an input starting with "bad".
+type fakeEmbedder struct {
+	mu    gosync.Mutex
+	calls [][]string
+}
+
+func (f *fakeEmbedder) Embed(_ context.Context, inputs []string) ([]models.Embedding, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, inputs)
+	f.mu.Unlock()
+	vectors := make([]models.Embedding, len(inputs))
+	for i, input := range inputs {
+		if strings.HasPrefix(input, "bad") {
+			return nil, errors.New("input too long")
+		}
+		vectors[i] = models.Embedding{float64(len(input))}
+	}
+	return vectors, nil
+}
+
+func (f *fakeEmbedder) Dimensions(context.Context) (int, error) { return 1, nil }
+
+func (f *fakeEmbedder) Name() string { return "fake" }
+
+func TestEmbedBatch(t *testing.T) {
+	t.Run("single request", func(t *testing.T) {
+		embedder := &fakeEmbedder{}
+		results := embedBatch(context.Background(), embedder, newBatchLimits(10, 100), []pendingFile{pending("a"), pending("bb", "ccc")})
+
+		require.Len(t, results, 2)
+		assert.Len(t, embedder.calls, 1)
+		assert.Equal(t, []models.Embedding{{1}}, results[0].embeddings)
+		assert.Equal(t, []models.Embedding{{2}, {3}}, results[1].embeddings)
+	})
+
+	t.Run("large file is split into several requests", func(t *testing.T) {
+		embedder := &fakeEmbedder{}
+		results := embedBatch(context.Background(), embedder, newBatchLimits(2, 100), []pendingFile{pending("a", "bb", "ccc")})
+
+		require.Len(t, results, 1)
+		assert.Equal(t, [][]string{{"a", "bb"}, {"ccc"}}, embedder.calls)
+		assert.Equal(t, []models.Embedding{{1}, {2}, {3}}, results[0].embeddings)
+	})
+
+	t.Run("failed batch is retried individually", func(t *testing.T) {
+		embedder := &fakeEmbedder{}
+		results := embedBatch(context.Background(), embedder, newBatchLimits(10, 100), []pendingFile{pending("a"), pending("bad"), pending("ccc")})
+
+		require.Len(t, results, 3)
+		assert.Len(t, embedder.calls, 4)
+		assert.NoError(t, results[0].err)
+		assert.Error(t, results[1].err)
+		assert.Nil(t, results[1].embeddings)
+		assert.Equal(t, []models.Embedding{{3}}, results[2].embeddings)
+	})
+}
@@ -0,0 +1,294 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/andrejsstepanovs/codesearch/chunk"
+	"github.com/andrejsstepanovs/codesearch/client"
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/models"
+	codeproject "github.com/andrejsstepanovs/codesearch/project"
+)
+
+// DefaultWorkers is the default number of files read and embedded concurrently.
+const DefaultWorkers = 1
+
+// prepareFile reads a local file and decides whether it needs embedding.
+// unchanged is true when the content did not change since it was last stored;
+// item is then only set if the stored size and modification time are stale.
+// Files rejected by the content filter are returned with skipped set.
+func prepareFile(filePath, relativePath string, existing map[string]models.File, config *Config) (item *pendingFile, unchanged bool, err error) {
+	fileRecord, known := existing[relativePath]
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	item = &pendingFile{
+		path:   filePath,
+		meta:   models.File{File: relativePath},
+		fileID: fileRecord.ID,
+	}
+
+	if reason := config.Filter.CheckSize(info.Size()); reason != "" {
+		item.skipped = reason
+		return item, false, nil
+	}
+
+	// Same size and modification time as last sync: skip without reading.
+	if known && fileRecord.Hash != "" && info.Size() == fileRecord.Size && info.ModTime().Equal(fileRecord.ModTime) {
+		return nil, true, nil
+	}
+
+	content, meta, err := readSourceFile(filePath, relativePath, info)
+	if err != nil {
+		return nil, false, err
+	}
+	item.meta = meta
+
+	if known && meta.Hash == fileRecord.Hash {
+		// Touched but not modified: the writer records the new mtime for the fast path.
+		return item, true, nil
+	}
+
+	if reason := config.Filter.Check(relativePath, content); reason != "" {
+		item.skipped = reason
+		return item, false, nil
+	}
+
+	chunks := chunk.Split(relativePath, content, config.Chunking)
+	template := config.Templates.Document
+	header := codeproject.RenderDocument(template, relativePath, "", "")
+	item.chunks, item.split, item.truncated = chunk.Fit(chunks, contentBudget(header, config.inputTokenBudget()))
+	for _, c := range item.chunks {
+		item.inputs = append(item.inputs, codeproject.RenderDocument(template, relativePath, c.Symbol, c.Content))
+	}
+	return item, false, nil
+}
+
+// contentBudget returns the approximate number of tokens left for chunk
+// content once header, the document text without symbol and content, and the
+// symbol are added. A tenth of maxTokens is kept in reserve because token
+// counts are only estimated.
+func contentBudget(header string, maxTokens int) int {
+	if maxTokens <= 0 {
+		return 0
+	}
+	budget := maxTokens*9/10 - chunk.EstimateTokens(header) - symbolTokenReserve
+	if budget < 1 {
+		budget = 1
+	}
+	return budget
+}
+
+// symbolTokenReserve is the token allowance for the symbol in a chunk header.
+const symbolTokenReserve = 32
+
+// indexResult is what a worker reports to the writer for a single file.
+type indexResult struct {
+	embeddedFile
+	unchanged bool
+}
+
+// indexFiles embeds the new and changed files among files and stores them.
+// existing maps relative paths to already stored records.
+//
+// Files are read and embedded by config.Workers workers, each batching its
+// own requests, while all database writes happen on the calling goroutine.
+func indexFiles(ctx context.Context, dbConn *sql.DB, embedder client.Embedder, config *Config, files []string, existing map[string]models.File) (syncStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := config.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	paths := make(chan string)
+	results := make(chan indexResult, workers)
+
+	go func() {
+		defer close(paths)
+		for _, filePath := range files {
+			select {
+			case paths <- filePath:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			indexWorker(ctx, embedder, config, existing, paths, results)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var stats syncStats
+	var writeErr error
+	processed, lastPercentage := 0, -1
+	for res := range results {
+		if writeErr != nil {
+			continue // drain so workers can exit
+		}
+
+		writeErr = storeResult(dbConn, res, &stats)
+		if writeErr != nil {
+			cancel()
+			continue
+		}
+
+		processed++
+		if percentage := processed * 100 / len(files); percentage != lastPercentage {
+			lastPercentage = percentage
+			fmt.Fprintf(config.progress(), "Progress: %d%%\n", percentage)
+		}
+	}
+
+	if writeErr != nil {
+		return stats, writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// indexWorker reads paths until the channel is closed, embeds changed files in
+// batches and reports one result per path.
+func indexWorker(ctx context.Context, embedder client.Embedder, config *Config, existing map[string]models.File, paths <-chan string, results chan<- indexResult) {
+	limits := newBatchLimits(config.BatchSize, config.BatchBytes)
+	send := func(res indexResult) bool {
+		select {
+		case results <- res:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	sendBatch := func(batch []pendingFile) bool {
+		for _, res := range embedBatch(ctx, embedder, limits, batch) {
+			if ctx.Err() != nil {
+				return false
+			}
+			if !send(indexResult{embeddedFile: res}) {
+				return false
+			}
+		}
+		return true
+	}
+
+	b := newBatcher(limits)
+	for filePath := range paths {
+		relativePath := strings.TrimPrefix(filePath, config.ProjectPath)
+		item, unchanged, err := prepareFile(filePath, relativePath, existing, config)
+		if err != nil {
+			err = fmt.Errorf("error reading file: %w", err)
+			if !send(indexResult{embeddedFile: embeddedFile{pendingFile: pendingFile{path: filePath}, err: err}}) {
+				return
+			}
+			continue
+		}
+
+		if unchanged {
+			res := indexResult{unchanged: true}
+			if item != nil {
+				res.pendingFile = *item
+			}
+			if !send(res) {
+				return
+			}
+			continue
+		}
+
+		if item.skipped != "" {
+			if !send(indexResult{embeddedFile: embeddedFile{pendingFile: *item}}) {
+				return
+			}
+			continue
+		}
+
+		if batch := b.add(*item); batch != nil {
+			if !sendBatch(batch) {
+				return
+			}
+		}
+	}
+
+	if batch := b.flush(); batch != nil {
+		sendBatch(batch)
+	}
+}
+
+// storeResult applies a single worker result to the database.
+func storeResult(dbConn *sql.DB, res indexResult, stats *syncStats) error {
+	if res.unchanged {
+		if res.fileID != 0 {
+			err := db.TouchFile(dbConn, res.fileID, res.meta.Size, res.meta.ModTime)
+			if err != nil {
+				return fmt.Errorf("error updating metadata for file %s: %w", res.path, err)
+			}
+		}
+		stats.Unchanged++
+		return nil
+	}
+
+	if res.skipped != "" {
+		// A file that became e.g. generated or too large leaves the index.
+		if res.fileID != 0 {
+			err := db.DeleteFileAndVector(dbConn, res.fileID)
+			if err != nil {
+				return fmt.Errorf("error deleting skipped file %s: %w", res.path, err)
+			}
+		}
+		stats.Skipped = append(stats.Skipped, fmt.Sprintf("%s (%s)", res.meta.File, res.skipped))
+		return nil
+	}
+
+	if res.err != nil {
+		log.Printf("Error processing file %s: %v", res.path, res.err)
+		stats.Failed = append(stats.Failed, res.path)
+		return nil
+	}
+
+	if res.split {
+		stats.Split = append(stats.Split, res.meta.File)
+	}
+	if res.truncated {
+		log.Printf("Warning: lines of %s exceed the model input budget and were truncated", res.meta.File)
+		stats.Truncated = append(stats.Truncated, res.meta.File)
+	}
+
+	if res.fileID != 0 {
+		log.Printf("Updating file: %s", res.meta.File)
+		err := db.ReplaceFileChunks(dbConn, res.fileID, res.meta, res.chunks, res.embeddings)
+		if err != nil {
+			return fmt.Errorf("error updating embedding for file %s: %w", res.path, err)
+		}
+		stats.Updated++
+		return nil
+	}
+
+	log.Printf("Adding new file: %s", res.meta.File)
+	_, err := db.SaveFileChunks(dbConn, res.meta, res.chunks, res.embeddings)
+	if err != nil {
+		return fmt.Errorf("error saving embedding for file %s: %w", res.path, err)
+	}
+	stats.Added++
+	return nil
+}
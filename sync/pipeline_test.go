@@ -0,0 +1,177 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrejsstepanovs/codesearch/db"
+	"github.com/andrejsstepanovs/codesearch/file"
+	"github.com/andrejsstepanovs/codesearch/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexFiles(t *testing.T) {
+	projectDir := t.TempDir()
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "index"), 1)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	var files []string
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go", "e.go"} {
+		path := filepath.Join(projectDir, name)
+		require.NoError(t, os.WriteFile(path, []byte("package "+name[:1]), 0644))
+		files = append(files, path)
+	}
+
+	config := &Config{ProjectPath: projectDir, BatchSize: 2, Workers: 3}
+	embedder := &fakeEmbedder{}
+
+	stats, err := indexFiles(context.Background(), dbConn, embedder, config, files, nil)
+	require.NoError(t, err)
+	assert.Equal(t, syncStats{Added: 5}, stats)
+
+	// Modify one file and only touch another.
+	require.NoError(t, os.WriteFile(files[0], []byte("package changed"), 0644))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(files[1], later, later))
+
+	existing := loadExisting(t, dbConn)
+	stats, err = indexFiles(context.Background(), dbConn, embedder, config, files, existing)
+	require.NoError(t, err)
+	assert.Equal(t, syncStats{Updated: 1, Unchanged: 4}, stats)
+
+	// The touched file now takes the fast path without being read.
+	existing = loadExisting(t, dbConn)
+	assert.True(t, later.Equal(existing["/b.go"].ModTime))
+	stats, err = indexFiles(context.Background(), dbConn, embedder, config, files, existing)
+	require.NoError(t, err)
+	assert.Equal(t, syncStats{Unchanged: 5}, stats)
+}
+
+func TestIndexFilesCancelled(t *testing.T) {
+	projectDir := t.TempDir()
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "cancel"), 1)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	path := filepath.Join(projectDir, "a.go")
+	require.NoError(t, os.WriteFile(path, []byte("package a"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = indexFiles(ctx, dbConn, &fakeEmbedder{}, &Config{ProjectPath: projectDir, Workers: 2}, []string{path}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func loadExisting(t *testing.T, dbConn *sql.DB) map[string]models.File {
+	files, err := db.GetFilesToSync(dbConn)
+	require.NoError(t, err)
+
+	existing := make(map[string]models.File, len(files))
+	for _, f := range files {
+		existing[f.File] = f
+	}
+	return existing
+}
+
+func TestIndexFilesOversized(t *testing.T) {
+	projectDir := t.TempDir()
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "oversized"), 1)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	small := filepath.Join(projectDir, "small.py")
+	require.NoError(t, os.WriteFile(small, []byte("x = 1\n"), 0644))
+
+	var big strings.Builder
+	for range 200 {
+		big.WriteString("value = compute(value) + 1\n")
+	}
+	large := filepath.Join(projectDir, "large.py")
+	require.NoError(t, os.WriteFile(large, []byte(big.String()), 0644))
+
+	minified := filepath.Join(projectDir, "min.js")
+	require.NoError(t, os.WriteFile(minified, []byte(strings.Repeat("a", 4000)), 0644))
+
+	config := &Config{ProjectPath: projectDir, MaxTokens: 200, Filter: file.Filter{IncludeMinified: true}}
+	stats, err := indexFiles(context.Background(), dbConn, &fakeEmbedder{}, config, []string{small, large, minified}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, stats.Added)
+	assert.ElementsMatch(t, []string{"/large.py", "/min.js"}, stats.Split)
+	assert.Equal(t, []string{"/min.js"}, stats.Truncated)
+
+	var chunkCount int
+	require.NoError(t, dbConn.QueryRow("SELECT COUNT(*) FROM chunks c JOIN files f ON f.id = c.file_id WHERE f.file = '/large.py'").Scan(&chunkCount))
+	assert.Greater(t, chunkCount, 1)
+}
+
+func TestIndexFilesSkipped(t *testing.T) {
+	projectDir := t.TempDir()
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "skipped"), 1)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	source := filepath.Join(projectDir, "a.go")
+	require.NoError(t, os.WriteFile(source, []byte("package a\n"), 0644))
+	generated := filepath.Join(projectDir, "a_gen.go")
+	require.NoError(t, os.WriteFile(generated, []byte("package a\n"), 0644))
+	binary := filepath.Join(projectDir, "data.bin")
+	require.NoError(t, os.WriteFile(binary, []byte("data\n"), 0644))
+	large := filepath.Join(projectDir, "large.py")
+	require.NoError(t, os.WriteFile(large, []byte(strings.Repeat("x = 1\n", 100)), 0644))
+
+	config := &Config{ProjectPath: projectDir}
+	files := []string{source, generated, binary, large}
+	stats, err := indexFiles(context.Background(), dbConn, &fakeEmbedder{}, config, files, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Added)
+
+	// Files that turn generated, binary or too large leave the index.
+	require.NoError(t, os.WriteFile(generated, []byte("// Code generated by stringer. DO NOT EDIT.\n\npackage a\n"), 0644))
+	require.NoError(t, os.WriteFile(binary, []byte{0x7f, 'E', 'L', 'F', 0, 1}, 0644))
+	config.Filter.MaxSize = 100
+
+	stats, err = indexFiles(context.Background(), dbConn, &fakeEmbedder{}, config, files, loadExisting(t, dbConn))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, stats.Unchanged)
+	assert.ElementsMatch(t, []string{
+		"/a_gen.go (" + file.SkipGenerated + ")",
+		"/data.bin (" + file.SkipBinary + ")",
+		"/large.py (" + file.SkipTooLarge + ")",
+	}, stats.Skipped)
+	assert.Equal(t, []string{"/a.go"}, keys(loadExisting(t, dbConn)))
+}
+
+func keys(m map[string]models.File) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestIndexFilesDocumentTemplate(t *testing.T) {
+	projectDir := t.TempDir()
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "template"), 1)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	source := filepath.Join(projectDir, "a.py")
+	require.NoError(t, os.WriteFile(source, []byte("x = 1\n"), 0644))
+
+	config := &Config{ProjectPath: projectDir, Templates: models.Templates{Document: "search_document: {language} {path}\n{content}"}}
+	embedder := &fakeEmbedder{}
+	_, err = indexFiles(context.Background(), dbConn, embedder, config, []string{source}, nil)
+	require.NoError(t, err)
+	require.Len(t, embedder.calls, 1)
+	assert.Equal(t, []string{"search_document: Python /a.py\nx = 1\n"}, embedder.calls[0])
+}
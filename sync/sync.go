@@ -4,23 +4,119 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/andrejsstepanovs/codesearch/chunk"
 	"github.com/andrejsstepanovs/codesearch/client"
 	"github.com/andrejsstepanovs/codesearch/db"
 	"github.com/andrejsstepanovs/codesearch/file"
 	"github.com/andrejsstepanovs/codesearch/models"
+	codeproject "github.com/andrejsstepanovs/codesearch/project"
 )
 
+// syncStats counts what a sync did to each file and lists the files that
+// needed attention.
+type syncStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+	Failed    []string // files that could not be read or embedded
+	Split     []string // files with chunks split to fit the input budget
+	Truncated []string // files with content truncated to fit the input budget
+	Skipped   []string // files rejected by the content filter, with reason
+}
+
+func (s syncStats) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d unchanged, %d removed, %d skipped, %d failed",
+		s.Added, s.Updated, s.Unchanged, s.Removed, len(s.Skipped), len(s.Failed))
+}
+
+// printReport prints the summary line followed by the files that needed attention.
+func (s syncStats) printReport(w io.Writer, message string) {
+	fmt.Fprintf(w, "%s: %s\n", message, s)
+	printFiles(w, "Skipped files", s.Skipped)
+	printFiles(w, "Failed files", s.Failed)
+	printFiles(w, "Files split to fit the model input budget", s.Split)
+	printFiles(w, "Files truncated to fit the model input budget", s.Truncated)
+}
+
+func printFiles(w io.Writer, title string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s (%d):\n", title, len(files))
+	for _, f := range files {
+		fmt.Fprintf(w, "  %s\n", f)
+	}
+}
+
 type Config struct {
 	ProjectAlias string
 	ProjectPath  string
 	ClientName   string
 	ModelName    string
 	Extensions   []string
+	// Exclude are gitignore-style patterns of files to skip, in addition to
+	// .gitignore and .codesearchignore files.
+	Exclude []string
+	// ClientOptions override how the embedding provider is reached.
+	ClientOptions client.Options
+	// BatchSize is the maximum number of files embedded per request.
+	BatchSize int
+	// BatchBytes is the maximum total input size in bytes per request.
+	BatchBytes int
+	// Workers is the number of files read and embedded concurrently.
+	Workers int
+	// Filter decides which files are skipped based on their content.
+	Filter file.Filter
+	// Chunking configures how files are split before embedding.
+	Chunking chunk.Options
+	// MaxTokens is the approximate input limit of the model per chunk.
+	// Zero uses the known limit of the model.
+	MaxTokens int
+	// Templates format the text embedded for chunks and, at search time,
+	// queries.
+	Templates models.Templates
+	// Output receives the final report, standard output if nil.
+	Output io.Writer
+	// Progress receives progress updates, Output if nil.
+	Progress io.Writer
+}
+
+func (c *Config) output() io.Writer {
+	if c.Output == nil {
+		return os.Stdout
+	}
+	return c.Output
+}
+
+func (c *Config) progress() io.Writer {
+	if c.Progress == nil {
+		return c.output()
+	}
+	return c.Progress
+}
+
+func (c *Config) fileOptions() file.Options {
+	return file.Options{
+		Extensions: c.Extensions,
+		Exclude:    c.Exclude,
+	}
+}
+
+// inputTokenBudget returns the approximate input limit per chunk, using the
+// known limit of the model unless MaxTokens is set.
+func (c *Config) inputTokenBudget() int {
+	if c.MaxTokens > 0 {
+		return c.MaxTokens
+	}
+	return client.MaxInputTokens(c.ModelName)
 }
 
 func ParseConfig(args []string) (*Config, error) {
@@ -34,6 +130,11 @@ func ParseConfig(args []string) (*Config, error) {
 		ModelName:    "codesearch-embedding",
 		ClientName:   "litellm",
 		Extensions:   []string{"go", "js", "ts", "py", "java", "cpp", "c", "h", "hpp", "yaml", "yml"},
+		BatchSize:    DefaultBatchSize,
+		BatchBytes:   DefaultBatchBytes,
+		Workers:      DefaultWorkers,
+		Filter:       file.DefaultFilter(),
+		Chunking:     chunk.DefaultOptions(),
 	}
 
 	if config.ProjectPath == "." {
@@ -59,57 +160,86 @@ func ParseConfig(args []string) (*Config, error) {
 	return config, nil
 }
 
-func processProjectFiles(ctx context.Context, dbConn *sql.DB, config *Config) error {
-	log.Println("Syncing code files to the database")
-	files, err := file.RecursiveFiles(config.ProjectPath, config.Extensions)
+// readSourceFile reads filePath and returns its content together with the
+// metadata used for change detection.
+func readSourceFile(filePath, relativePath string, info os.FileInfo) ([]byte, models.File, error) {
+	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("error finding files: %w", err)
+		return nil, models.File{}, err
 	}
 
-	log.Printf("Found %d files", len(files))
+	meta := models.File{
+		File:    relativePath,
+		Hash:    file.Hash(content),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
 
-	for i, filePath := range files {
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			log.Printf("Error reading file %s: %v", filePath, err)
-			continue
-		}
-		relativePath := strings.TrimPrefix(filePath, config.ProjectPath)
+	return content, meta, nil
+}
 
-		log.Printf("Processing file: %s", relativePath)
-		embed := fmt.Sprintf("%s\n%s", relativePath, string(content))
-		res, err := client.Embeddings(ctx, config.ClientName, config.ModelName, embed)
-		if err != nil {
-			log.Printf("Error generating embeddings for file %s: %v", filePath, err)
+// rebuildKeywordIndex fills the keyword index from the project sources for
+// files that did not change since they were indexed, and returns the number
+// of chunks indexed. Changed files are indexed again by the sync itself.
+func rebuildKeywordIndex(dbConn *sql.DB, root string, files []models.File) (int, error) {
+	var chunks []db.KeywordChunk
+	for _, fileRecord := range files {
+		content, err := os.ReadFile(filepath.Join(root, fileRecord.File))
+		if err != nil || file.Hash(content) != fileRecord.Hash {
 			continue
 		}
+		lines := strings.Split(string(content), "\n")
 
-		_, err = db.SaveFileEmbedding(dbConn, relativePath, res.GetEmbeddings())
+		stored, err := db.GetFileChunks(dbConn, fileRecord.ID)
 		if err != nil {
-			return fmt.Errorf("error saving embedding for file %s: %w", filePath, err)
+			return 0, err
+		}
+		for _, c := range stored {
+			text := string(content)
+			if c.StartLine > 0 && c.StartLine <= len(lines) {
+				text = strings.Join(lines[c.StartLine-1:min(c.EndLine, len(lines))], "\n")
+			}
+			chunks = append(chunks, db.KeywordChunk{ChunkID: c.ID, File: fileRecord.File, Symbol: c.Symbol, Content: text})
 		}
+	}
 
-		percentage := float64(i+1) / float64(len(files)) * 100
-		fmt.Printf("Progress: %.2f%%\n", percentage)
+	if err := db.RebuildKeywordIndex(dbConn, chunks); err != nil {
+		return 0, err
 	}
+	return len(chunks), nil
+}
 
-	return nil
+func processProjectFiles(ctx context.Context, dbConn *sql.DB, embedder client.Embedder, config *Config) (syncStats, error) {
+	log.Println("Syncing code files to the database")
+	files, err := file.RecursiveFiles(config.ProjectPath, config.fileOptions())
+	if err != nil {
+		return syncStats{}, fmt.Errorf("error finding files: %w", err)
+	}
+
+	log.Printf("Found %d files", len(files))
+
+	return indexFiles(ctx, dbConn, embedder, config, files, nil)
 }
 
 // Run builds
 func Run(ctx context.Context, config *Config) error {
-	res, err := client.Embeddings(ctx, config.ClientName, config.ModelName, "1")
+	if err := codeproject.ValidateTemplates(config.Templates); err != nil {
+		return err
+	}
+
+	embedder, err := client.New(config.ClientName, config.ModelName, config.ClientOptions)
 	if err != nil {
-		return fmt.Errorf("error generating embedding for dimensions: %w", err)
+		return fmt.Errorf("error creating embedding client: %w", err)
 	}
-	dimensions := len(res.GetEmbeddings().Float32())
-	if dimensions == 0 {
-		return fmt.Errorf("received empty embedding dimensions")
+
+	identity, err := client.Identify(ctx, embedder, config.ModelName)
+	if err != nil {
+		return err
 	}
 
-	dbConn, err := db.SetupDatabase(config.ProjectAlias, dimensions)
+	dbConn, err := db.CreateDatabase(config.ProjectAlias, config.ProjectPath, identity.Dimensions)
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return err
 	}
 	defer dbConn.Close()
 
@@ -120,6 +250,17 @@ func Run(ctx context.Context, config *Config) error {
 		Client:     config.ClientName,
 		Model:      config.ModelName,
 		Extensions: config.Extensions,
+		Exclude:    config.Exclude,
+
+		MaxFileSize:      config.Filter.MaxSize,
+		IncludeGenerated: config.Filter.IncludeGenerated,
+		IncludeMinified:  config.Filter.IncludeMinified,
+
+		ChunkLines:   config.Chunking.Lines.MaxLines,
+		ChunkOverlap: config.Chunking.Lines.Overlap,
+		ChunkTokens:  config.Chunking.Lines.MaxTokens,
+		MaxTokens:    config.MaxTokens,
+		Templates:    config.Templates,
 	}
 
 	err = db.UpsertProject(dbConn, project)
@@ -132,126 +273,138 @@ func Run(ctx context.Context, config *Config) error {
 		return fmt.Errorf("error deleting existing vector data: %w", err)
 	}
 
-	err = processProjectFiles(ctx, dbConn, config)
+	// A model with another vector size needs a new vector table.
+	err = db.ResizeVectors(dbConn, identity.Dimensions)
+	if err != nil {
+		return err
+	}
+
+	stats, err := processProjectFiles(ctx, dbConn, embedder, config)
 	if err != nil {
 		return fmt.Errorf("error processing project files: %w", err)
 	}
 
-	fmt.Printf("Project '%s' built successfully\n", config.ProjectAlias)
+	// Until the index is built the previous identity stays, so that find
+	// keeps pointing to a rebuild after a failed build.
+	err = db.SetProjectIdentity(dbConn, config.ProjectAlias, identity.Dimensions, identity.Fingerprint)
+	if err != nil {
+		return err
+	}
+
+	err = db.MarkProjectSynced(dbConn, config.ProjectAlias, time.Now())
+	if err != nil {
+		return err
+	}
+
+	stats.printReport(config.output(), fmt.Sprintf("Project '%s' built successfully", config.ProjectAlias))
 	return nil
 }
 
-// RunSync runs a sync operation using stored project configuration
-func RunSync(ctx context.Context, projectAlias string) error {
-	dbConn, err := db.SetupDatabase(projectAlias, 0)
+// RunSync runs a sync operation using stored project configuration.
+// Only ProjectAlias and runtime options of config are used, the rest is
+// loaded from the project metadata.
+func RunSync(ctx context.Context, config *Config) error {
+	dbConn, err := db.SetupDatabase(config.ProjectAlias, 0)
 	if err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer dbConn.Close()
 
-	project, err := db.GetProjectByAlias(dbConn, projectAlias)
+	project, err := db.GetProjectByAlias(dbConn, config.ProjectAlias)
 	if err != nil {
-		return fmt.Errorf("failed to get project config for alias '%s': %w", projectAlias, err)
+		return fmt.Errorf("failed to get project config for alias '%s': %w", config.ProjectAlias, err)
 	}
 
-	config := &Config{
-		ProjectAlias: project.Alias,
-		ProjectPath:  project.Path,
-		ModelName:    project.Model,
-		ClientName:   project.Client,
-		Extensions:   project.Extensions,
+	config.ProjectAlias = project.Alias
+	config.ProjectPath = project.Path
+	config.ModelName = project.Model
+	config.ClientName = project.Client
+	config.Extensions = project.Extensions
+	config.Exclude = project.Exclude
+	config.Filter = file.Filter{
+		MaxSize:          project.MaxFileSize,
+		IncludeGenerated: project.IncludeGenerated,
+		IncludeMinified:  project.IncludeMinified,
 	}
+	config.Chunking.Lines = chunk.LineOptions{
+		MaxLines:  project.ChunkLines,
+		Overlap:   project.ChunkOverlap,
+		MaxTokens: project.ChunkTokens,
+	}
+	config.MaxTokens = project.MaxTokens
+	config.Templates = project.Templates
 
-	// Fetch all local files
-	localFiles, err := file.RecursiveFiles(config.ProjectPath, config.Extensions)
+	embedder, err := client.New(config.ClientName, config.ModelName, config.ClientOptions)
 	if err != nil {
-		return fmt.Errorf("error finding local files: %w", err)
+		return fmt.Errorf("error creating embedding client: %w", err)
 	}
 
-	// Create a map of local file paths for quick lookup
-	localFilePaths := make(map[string]bool)
-	for _, filePath := range localFiles {
-		relativePath := strings.TrimPrefix(filePath, config.ProjectPath)
-		localFilePaths[relativePath] = true
+	identity, err := client.Identify(ctx, embedder, config.ModelName)
+	if err != nil {
+		return err
 	}
-
-	// Fetch all existing file paths from database
-	existingFilePaths, err := db.GetProjectFilePaths(dbConn)
+	err = codeproject.CheckModel(dbConn, project, identity)
 	if err != nil {
-		return fmt.Errorf("error getting existing file paths: %w", err)
+		return err
 	}
 
-	// Process new files
-	log.Println("Processing new files")
-	for _, filePath := range localFiles {
-		relativePath := strings.TrimPrefix(filePath, config.ProjectPath)
-
-		// Check if file is new (not in database)
-		if !existingFilePaths[relativePath] {
-			log.Printf("Adding new file: %s", relativePath)
-
-			content, err := os.ReadFile(filePath)
-			if err != nil {
-				log.Printf("Error reading file %s: %v", filePath, err)
-				continue
-			}
+	// Fetch all local files
+	localFiles, err := file.RecursiveFiles(config.ProjectPath, config.fileOptions())
+	if err != nil {
+		return fmt.Errorf("error finding local files: %w", err)
+	}
 
-			embed := fmt.Sprintf("%s\n%s", relativePath, string(content))
-			res, err := client.Embeddings(ctx, config.ClientName, config.ModelName, embed)
-			if err != nil {
-				log.Printf("Error generating embeddings for file %s: %v", filePath, err)
-				continue
-			}
+	// Fetch all existing file records from database, keyed by relative path
+	existingFiles, err := db.GetFilesToSync(dbConn)
+	if err != nil {
+		return fmt.Errorf("error getting files to sync: %w", err)
+	}
+	existing := make(map[string]models.File, len(existingFiles))
+	for _, fileRecord := range existingFiles {
+		existing[fileRecord.File] = fileRecord
+	}
 
-			_, err = db.SaveFileEmbedding(dbConn, relativePath, res.GetEmbeddings())
-			if err != nil {
-				return fmt.Errorf("error saving embedding for file %s: %w", filePath, err)
-			}
+	if !project.KeywordIndex && db.KeywordSearchAvailable() {
+		log.Println("Rebuilding keyword index")
+		chunks, err := rebuildKeywordIndex(dbConn, config.ProjectPath, existingFiles)
+		if err != nil {
+			return fmt.Errorf("error rebuilding keyword index: %w", err)
 		}
+		fmt.Fprintf(config.output(), "Keyword index rebuilt for %d chunks\n", chunks)
 	}
 
-	// Process existing files in order of creation date
-	log.Println("Processing existing files")
-	existingFiles, err := db.GetFilesToSync(dbConn)
+	log.Println("Processing local files")
+	stats, err := indexFiles(ctx, dbConn, embedder, config, localFiles, existing)
 	if err != nil {
-		return fmt.Errorf("error getting files to sync: %w", err)
+		return fmt.Errorf("error processing local files: %w", err)
+	}
+
+	localFilePaths := make(map[string]bool, len(localFiles))
+	for _, filePath := range localFiles {
+		relativePath := strings.TrimPrefix(filePath, config.ProjectPath)
+		localFilePaths[relativePath] = true
 	}
 
+	// Remove files that no longer exist locally
+	log.Println("Processing removed files")
 	for _, fileRecord := range existingFiles {
-		// Check if file still exists locally
 		if localFilePaths[fileRecord.File] {
-			// File exists, update it
-			log.Printf("Updating file: %s", fileRecord.File)
-
-			fullPath := filepath.Join(config.ProjectPath, fileRecord.File)
-			content, err := os.ReadFile(fullPath)
-			if err != nil {
-				log.Printf("Error reading file %s: %v", fullPath, err)
-				continue
-			}
-
-			embed := fmt.Sprintf("%s\n%s", fileRecord.File, string(content))
-			res, err := client.Embeddings(ctx, config.ClientName, config.ModelName, embed)
-			if err != nil {
-				log.Printf("Error generating embeddings for file %s: %v", fullPath, err)
-				continue
-			}
-
-			err = db.UpdateFileEmbedding(dbConn, fileRecord.ID, fileRecord.File, res.GetEmbeddings())
-			if err != nil {
-				return fmt.Errorf("error updating embedding for file %s: %w", fullPath, err)
-			}
-		} else {
-			// File was deleted, remove from database
-			log.Printf("Removing deleted file: %s", fileRecord.File)
+			continue
+		}
 
-			err := db.DeleteFileAndVector(dbConn, fileRecord.ID)
-			if err != nil {
-				return fmt.Errorf("error deleting file and vector for file %s: %w", fileRecord.File, err)
-			}
+		log.Printf("Removing deleted file: %s", fileRecord.File)
+		err := db.DeleteFileAndVector(dbConn, fileRecord.ID)
+		if err != nil {
+			return fmt.Errorf("error deleting file and vector for file %s: %w", fileRecord.File, err)
 		}
+		stats.Removed++
+	}
+
+	err = db.MarkProjectSynced(dbConn, config.ProjectAlias, time.Now())
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Project '%s' synced successfully\n", config.ProjectAlias)
+	stats.printReport(config.output(), fmt.Sprintf("Project '%s' synced successfully", config.ProjectAlias))
 	return nil
 }
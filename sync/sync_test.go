@@ -3,10 +3,12 @@ package sync
 import (
 	"context"
 	"database/sql"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/andrejsstepanovs/codesearch/client"
 	"github.com/andrejsstepanovs/codesearch/db"
 	"github.com/andrejsstepanovs/codesearch/models"
 	"github.com/stretchr/testify/assert"
@@ -30,21 +32,21 @@ func TestRun_FullRebuild(t *testing.T) {
 	config := &Config{
 		ProjectAlias: "test_project",
 		ProjectPath:  tempDir,
-		ModelName:    "codesearch-embedding",
-		ClientName:   "litellm",
+		ModelName:    "hash",
+		ClientName:   "hash",
 		Extensions:   []string{"go"},
 	}
 
-	deleteDbFile(t, config.ProjectAlias+".db")
+	t.Setenv(db.DataDirEnv, t.TempDir())
 
 	// Initialize database connection
-	dbConn, err := db.SetupDatabase(config.ProjectAlias, 1536)
+	dbConn, err := db.SetupDatabase(config.ProjectAlias, client.HashDimensions)
 	require.NoError(t, err)
 	defer dbConn.Close()
 
 	// Pre-populate database with dummy files to simulate existing data
 	// This simulates the scenario where we have old files in the database
-	dummyEmbedding := make(models.Embedding, 1536)
+	dummyEmbedding := make(models.Embedding, client.HashDimensions)
 	for i := range dummyEmbedding {
 		dummyEmbedding[i] = 0.1
 	}
@@ -134,11 +136,95 @@ func TestRun_FullRebuild(t *testing.T) {
 	assert.Equal(t, 0, fileCount) // dummy files should be deleted
 }
 
-func deleteDbFile(t *testing.T, file string) {
-	if _, err := os.Stat(file); err == nil {
-		err = os.Remove(file)
-		require.NoError(t, err, "Failed to remove existing test.db file")
-	} else if !os.IsNotExist(err) {
-		t.Fatalf("Unexpected error checking for test.db: %v", err)
+// sizedEmbedder returns vectors of a configurable size.
+type sizedEmbedder struct{ dimensions *int }
+
+func (e sizedEmbedder) Embed(_ context.Context, inputs []string) ([]models.Embedding, error) {
+	vectors := make([]models.Embedding, len(inputs))
+	for i := range vectors {
+		vectors[i] = make(models.Embedding, *e.dimensions)
+		vectors[i][0] = 1
 	}
+	return vectors, nil
+}
+
+func (e sizedEmbedder) Dimensions(context.Context) (int, error) { return *e.dimensions, nil }
+
+func (e sizedEmbedder) Name() string { return "sized" }
+
+func TestRun_DimensionChange(t *testing.T) {
+	dimensions := 8
+	client.Register(client.Provider{
+		Name:    "sized",
+		Factory: func(string, client.Options) client.Embedder { return sizedEmbedder{&dimensions} },
+		Offline: true,
+	})
+	t.Setenv(db.DataDirEnv, t.TempDir())
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	config := &Config{ProjectAlias: "sized", ProjectPath: projectDir, ClientName: "sized", ModelName: "m", Extensions: []string{"go"}, Output: io.Discard}
+	require.NoError(t, Run(context.Background(), config))
+
+	// The model now returns larger vectors: the rebuild replaces the table.
+	dimensions = 16
+	require.NoError(t, Run(context.Background(), config))
+
+	dbConn, err := db.SetupDatabase("sized", 0)
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	stored, err := db.VectorDimensions(dbConn)
+	require.NoError(t, err)
+	assert.Equal(t, 16, stored)
+
+	project, err := db.GetProjectByAlias(dbConn, "sized")
+	require.NoError(t, err)
+	assert.Equal(t, 16, project.Dimensions)
+
+	stats, err := db.GetStats(dbConn)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Files)
+
+	// A build that fails keeps the identity of the last complete index, so
+	// that find still asks for a rebuild.
+	dimensions = 32
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, Run(cancelled, config))
+
+	project, err = db.GetProjectByAlias(dbConn, "sized")
+	require.NoError(t, err)
+	assert.Equal(t, 16, project.Dimensions)
+}
+
+func TestRunSync_RebuildsKeywordIndex(t *testing.T) {
+	t.Setenv(db.DataDirEnv, t.TempDir())
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "config.go"), []byte("package app\n\nfunc parseConfig() {}\n"), 0644))
+
+	config := &Config{ProjectAlias: "keywords", ProjectPath: projectDir, ClientName: "hash", ModelName: "hash", Extensions: []string{"go"}, Output: io.Discard}
+	require.NoError(t, Run(context.Background(), config))
+
+	dbConn, err := db.SetupDatabase("keywords", 0)
+	require.NoError(t, err)
+	defer dbConn.Close()
+	if !db.KeywordSearchAvailable() {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+
+	// Simulate an index built before keyword search existed.
+	_, err = dbConn.Exec("DELETE FROM chunks_fts; UPDATE projects SET keyword_index = 0")
+	require.NoError(t, err)
+
+	require.NoError(t, RunSync(context.Background(), &Config{ProjectAlias: "keywords", Output: io.Discard}))
+
+	results, err := db.SearchKeyword(dbConn, "parseConfig", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "/config.go", results[0].File)
+
+	project, err := db.GetProjectByAlias(dbConn, "keywords")
+	require.NoError(t, err)
+	assert.True(t, project.KeywordIndex)
 }